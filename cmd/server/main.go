@@ -1,22 +1,72 @@
 package main
 
 import (
-	"log"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
 
 	"tf-monitor/internal/api"
+	"tf-monitor/internal/cli"
 	"tf-monitor/internal/config"
+	"tf-monitor/internal/logging"
+	"tf-monitor/internal/metrics"
 	"tf-monitor/internal/model"
 	"tf-monitor/internal/repository"
+	"tf-monitor/internal/secret"
+	"tf-monitor/internal/service/auth"
+	"tf-monitor/internal/service/bot"
+	"tf-monitor/internal/service/events"
+	"tf-monitor/internal/service/live"
 	"tf-monitor/internal/service/scheduler"
+	"tf-monitor/internal/service/webhook"
+	"tf-monitor/web"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
-	cfg := config.Load()
+	if len(os.Args) > 1 && os.Args[1] != "serve" {
+		if !cli.IsCommand(os.Args[1]) {
+			fmt.Fprintf(os.Stderr, "Unknown command %q, see tf-monitor help\n", os.Args[1])
+			os.Exit(2)
+		}
+		os.Exit(cli.Run(os.Args[1:]))
+	}
+	serve()
+}
+
+// serve runs the server until it fails to listen
+func serve() {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid configuration:", err)
+		os.Exit(1)
+	}
+
+	if err := logging.Setup(cfg.Log, os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if err := secret.Configure(cfg.Secret.Key, cfg.Secret.PreviousKeys); err != nil {
+		fatal("Failed to load secret key", logging.Err(err))
+	}
 
 	if err := repository.InitDB(cfg.Database.Path); err != nil {
-		log.Fatalf("Failed to init database: %v", err)
+		fatal("Failed to init database", logging.Err(err))
+	}
+
+	if secret.Enabled() {
+		if err := repository.ReencryptSecrets(); err != nil {
+			fatal("Failed to encrypt secrets", logging.Err(err))
+		}
+	} else {
+		slog.Warn("SECRET_KEY not set, secrets are stored unencrypted")
+	}
+
+	if err := auth.Bootstrap(cfg.Auth.AdminUsername, cfg.Auth.AdminPassword); err != nil {
+		fatal("Failed to create admin user", logging.Err(err))
 	}
 
 	proxyURL := ""
@@ -27,28 +77,94 @@ func main() {
 	sched := scheduler.GetScheduler()
 	sched.Init(proxyURL)
 
-	var telegramCfg model.TelegramConfig
-	if repository.GetDB().First(&telegramCfg).Error == nil && telegramCfg.Enabled {
-		sched.UpdateNotifier(telegramCfg.BotToken, telegramCfg.ChatID)
+	policy := model.NotifyPolicy{
+		MinRepeatInterval: cfg.Notify.MinRepeatInterval,
+		MaxPerHour:        cfg.Notify.MaxPerHour,
+		QuietHours:        cfg.Notify.QuietHours,
+		QuietMode:         model.QuietMode(cfg.Notify.QuietMode),
+		Timezone:          cfg.Notify.Timezone,
+	}
+	if err := policy.Validate(); err != nil {
+		fatal("Invalid notification policy", logging.Err(err))
+	}
+	sched.SetNotifyPolicy(policy, time.Duration(cfg.Notify.DedupWindow)*time.Second)
+	sched.SetPublicURL(cfg.Server.PublicURL)
+	sched.SetBotToken(cfg.Bot.Token)
+	if cfg.Server.PublicURL != "" {
+		// Created up front, alerts are built in a transaction that must not
+		// write it on the side
+		if _, err := repository.SigningKey(); err != nil {
+			fatal("Failed to load signing key", logging.Err(err))
+		}
+	}
+
+	live.Listen(events.GetBus())
+	metrics.Listen(events.GetBus())
+	webhook.Listen(events.GetBus())
+	webhook.AllowPrivateNetworks(cfg.Webhook.AllowPrivate)
+
+	var telegramCfgs []model.TelegramConfig
+	repository.GetDB().Where("enabled = ?", true).Find(&telegramCfgs)
+	for i := range telegramCfgs {
+		sched.UpdateNotifier(&telegramCfgs[i])
 	}
 
 	sched.Start()
 	defer sched.Stop()
+	webhook.Start()
+	defer webhook.Stop()
 
-	r := gin.Default()
+	basePath := cfg.Server.BasePath
+	r := gin.New()
+	r.Use(logging.Middleware(basePath+"/healthz", basePath+"/readyz", basePath+"/metrics"), gin.Recovery())
+	base := r.Group(basePath)
 
-	r.Static("/assets", "./web/dist/assets")
-	r.StaticFile("/", "./web/dist/index.html")
-	r.StaticFile("/favicon.ico", "./web/dist/favicon.ico")
-	r.NoRoute(func(c *gin.Context) {
-		c.File("./web/dist/index.html")
-	})
+	if err := web.Register(r, cfg.Server.WebDir, basePath); err != nil {
+		fatal("Failed to load frontend", logging.Err(err))
+	}
 
-	handler := api.NewHandler(proxyURL)
-	handler.RegisterRoutes(r)
+	var oidcProvider *auth.OIDCProvider
+	if cfg.OIDC.Enabled {
+		oidcProvider = auth.NewOIDCProvider(cfg.OIDC)
+	}
+
+	var trustedHeader *auth.TrustedHeader
+	if cfg.Header.Enabled {
+		th, err := auth.NewTrustedHeader(cfg.Header)
+		if err != nil {
+			fatal("Failed to configure trusted header auth", logging.Err(err))
+		}
+		trustedHeader = th
+	}
 
-	log.Printf("Server starting on :%s", cfg.Server.Port)
+	base.GET("/metrics", metrics.Handler(cfg.Metrics.Token))
+
+	handler := api.NewHandler(proxyURL, basePath, cfg.Auth, cfg.Health, oidcProvider, trustedHeader)
+	handler.RegisterRoutes(base)
+
+	if cfg.Bot.Token != "" {
+		b := bot.New(cfg.Bot, proxyURL)
+		if cfg.Bot.Mode == "webhook" {
+			if cfg.Bot.WebhookSecret == "" {
+				fatal("BOT_WEBHOOK_SECRET is required in webhook mode")
+			}
+			base.POST("/api/telegram/webhook", b.HandleWebhook)
+		}
+		if err := b.Start(); err != nil {
+			slog.Error("Failed to start Telegram bot", logging.Err(err))
+		} else {
+			defer b.Stop()
+		}
+	}
+
+	slog.Info("Server starting", "port", cfg.Server.Port, "base_path", basePath)
 	if err := r.Run(":" + cfg.Server.Port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+		fatal("Failed to start server", logging.Err(err))
 	}
 }
+
+// fatal logs an error that prevents the server from starting and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
@@ -0,0 +1,396 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"tf-monitor/internal/logging"
+	"tf-monitor/internal/metrics"
+	"tf-monitor/internal/model"
+	"tf-monitor/internal/repository"
+	"tf-monitor/internal/service/auth"
+	"tf-monitor/internal/service/scheduler"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	sessionCookie  = "tf_session"
+	contextUserKey = "user"
+)
+
+// RequireAuth resolves the trusted proxy header, session cookie or bearer token to a user
+func (h *Handler) RequireAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if h.trustedHeader != nil {
+			if username := c.GetHeader(h.trustedHeader.UserHeader()); username != "" {
+				h.authenticateTrustedHeader(c, username)
+				return
+			}
+		}
+
+		user, err := auth.LookupSession(sessionToken(c))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.Set(contextUserKey, user)
+		logging.With(c, "user_id", user.ID)
+		c.Next()
+	}
+}
+
+func (h *Handler) authenticateTrustedHeader(c *gin.Context, username string) {
+	if !h.trustedHeader.Trusted(c.Request.RemoteAddr) {
+		logging.FromContext(c).Warn("Rejected authentication header from untrusted source",
+			"header", h.trustedHeader.UserHeader(), "remote_addr", c.Request.RemoteAddr)
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "untrusted authentication header"})
+		return
+	}
+
+	user, err := h.trustedHeader.ResolveUser(username, c.GetHeader(h.trustedHeader.GroupsHeader()))
+	if errors.Is(err, auth.ErrUsernameTaken) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Set(contextUserKey, user)
+	logging.With(c, "user_id", user.ID)
+	c.Next()
+}
+
+// RequireRole rejects users whose role is not listed, must run after RequireAuth
+func (h *Handler) RequireRole(roles ...model.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := currentUser(c).Role
+		for _, r := range roles {
+			if role == r {
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
+	}
+}
+
+// RequireEditor rejects users who may not change monitors, must run after
+// RequireAuth
+func (h *Handler) RequireEditor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !currentUser(c).CanEdit() {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func currentUser(c *gin.Context) *model.User {
+	return c.MustGet(contextUserKey).(*model.User)
+}
+
+func sessionToken(c *gin.Context) string {
+	if header := c.GetHeader("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimPrefix(header, "Bearer ")
+	}
+	token, _ := c.Cookie(sessionCookie)
+	return token
+}
+
+type UserResponse struct {
+	ID        uint      `json:"id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func toUserResponse(u *model.User) UserResponse {
+	return UserResponse{
+		ID:        u.ID,
+		Username:  u.Username,
+		Role:      string(u.Role),
+		CreatedAt: u.CreatedAt,
+	}
+}
+
+func (h *Handler) Login(c *gin.Context) {
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := auth.Authenticate(req.Username, req.Password)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := h.startSession(c, user)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": toUserResponse(user), "token": token})
+}
+
+// startSession creates a session for the user and sets the session cookie
+func (h *Handler) startSession(c *gin.Context, user *model.User) (string, error) {
+	ttl := time.Duration(h.authCfg.SessionTTLHours) * time.Hour
+	token, err := auth.CreateSession(user.ID, ttl)
+	if err != nil {
+		return "", err
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(sessionCookie, token, int(ttl.Seconds()), h.cookiePath(), "", false, true)
+	return token, nil
+}
+
+// cookiePath scopes the session cookie to the base path, so other apps on the
+// same host neither see nor overwrite it
+func (h *Handler) cookiePath() string {
+	if h.basePath == "" {
+		return "/"
+	}
+	return h.basePath
+}
+
+func (h *Handler) GetAuthProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"local":         true,
+		"oidc":          h.oidc != nil,
+		"trustedHeader": h.trustedHeader != nil,
+	})
+}
+
+func (h *Handler) OIDCLogin(c *gin.Context) {
+	if h.oidc == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "sso not enabled"})
+		return
+	}
+
+	authURL, err := h.oidc.AuthCodeURL()
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	c.Redirect(http.StatusFound, authURL)
+}
+
+func (h *Handler) OIDCCallback(c *gin.Context) {
+	if h.oidc == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "sso not enabled"})
+		return
+	}
+
+	if errCode := c.Query("error"); errCode != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errCode + ": " + c.Query("error_description")})
+		return
+	}
+
+	user, err := h.oidc.Callback(c.Query("state"), c.Query("code"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := h.startSession(c, user); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Redirect(http.StatusFound, h.basePath+"/")
+}
+
+func (h *Handler) Logout(c *gin.Context) {
+	if token := sessionToken(c); token != "" {
+		auth.DeleteSession(token)
+	}
+	c.SetCookie(sessionCookie, "", -1, h.cookiePath(), "", false, true)
+	c.JSON(http.StatusOK, gin.H{"message": "logged out"})
+}
+
+func (h *Handler) GetCurrentUser(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": toUserResponse(currentUser(c))})
+}
+
+func (h *Handler) ChangePassword(c *gin.Context) {
+	var req struct {
+		OldPassword string `json:"oldPassword"`
+		NewPassword string `json:"newPassword"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.NewPassword) < 8 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "newPassword must be at least 8 characters"})
+		return
+	}
+
+	user := currentUser(c)
+	if _, err := auth.Authenticate(user.Username, req.OldPassword); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	hash, err := auth.HashPassword(req.NewPassword)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	repository.GetDB().Model(user).Update("password_hash", hash)
+
+	c.JSON(http.StatusOK, gin.H{"message": "saved"})
+}
+
+func (h *Handler) ListUsers(c *gin.Context) {
+	var users []model.User
+	repository.GetDB().Order("created_at asc").Find(&users)
+
+	result := make([]UserResponse, len(users))
+	for i, u := range users {
+		result[i] = toUserResponse(&u)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+func (h *Handler) CreateUser(c *gin.Context) {
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Role     string `json:"role"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Username = strings.TrimSpace(req.Username)
+	if req.Username == "" || len(req.Password) < 8 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "username and a password of at least 8 characters required"})
+		return
+	}
+
+	role := model.Role(req.Role)
+	if role == "" {
+		role = model.RoleEditor
+	}
+	if !role.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid role"})
+		return
+	}
+
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := model.User{
+		Username:     req.Username,
+		PasswordHash: hash,
+		Role:         role,
+	}
+	if err := repository.GetDB().Create(&user).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "username already exists"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": toUserResponse(&user)})
+}
+
+func (h *Handler) UpdateUser(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	var user model.User
+	if err := repository.GetDB().First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	var req struct {
+		Role     *string `json:"role"`
+		Password *string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.Role != nil {
+		role := model.Role(*req.Role)
+		if !role.Valid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid role"})
+			return
+		}
+		if user.ID == currentUser(c).ID && role != model.RoleAdmin {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cannot remove your own admin role"})
+			return
+		}
+		updates["role"] = role
+	}
+	if req.Password != nil {
+		if len(*req.Password) < 8 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "password must be at least 8 characters"})
+			return
+		}
+		hash, err := auth.HashPassword(*req.Password)
+		if err != nil {
+			c.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		updates["password_hash"] = hash
+	}
+
+	repository.GetDB().Model(&user).Updates(updates)
+	repository.GetDB().First(&user, id)
+
+	c.JSON(http.StatusOK, gin.H{"data": toUserResponse(&user)})
+}
+
+func (h *Handler) DeleteUser(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	if uint(id) == currentUser(c).ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot delete yourself"})
+		return
+	}
+
+	var user model.User
+	if err := repository.GetDB().First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	var monitors []model.Monitor
+	repository.GetDB().Where("user_id = ?", user.ID).Find(&monitors)
+	for i := range monitors {
+		scheduler.GetScheduler().StopJob(monitors[i].ID)
+		metrics.ForgetMonitor(&monitors[i])
+	}
+	scheduler.GetScheduler().RemoveNotifier(user.ID)
+
+	db := repository.GetDB()
+	db.Where("user_id = ?", user.ID).Delete(&model.Monitor{})
+	db.Where("user_id = ?", user.ID).Delete(&model.TelegramConfig{})
+	db.Unscoped().Where("user_id = ?", user.ID).Delete(&model.Session{})
+	db.Unscoped().Where("user_id = ?", user.ID).Delete(&model.Notification{})
+	db.Unscoped().Where("user_id = ?", user.ID).Delete(&model.MonitorEvent{})
+	db.Where("user_id = ?", user.ID).Delete(&model.Webhook{})
+	db.Unscoped().Where("user_id = ?", user.ID).Delete(&model.WebhookDelivery{})
+	db.Delete(&user)
+
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
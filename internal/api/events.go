@@ -0,0 +1,73 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"tf-monitor/internal/service/live"
+
+	"github.com/gin-gonic/gin"
+)
+
+// heartbeatInterval keeps idle streams from being closed by proxies
+const heartbeatInterval = 25 * time.Second
+
+// StreamEvents streams check results, status changes and notification
+// deliveries of the monitors the user can see as Server-Sent Events. A client
+// reconnecting with Last-Event-ID gets the events it missed, or a reset event
+// when they are gone and it has to reload.
+func (h *Handler) StreamEvents(c *gin.Context) {
+	user := currentUser(c)
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("lastEventId")
+	}
+
+	sub, replay := live.Subscribe(lastEventID)
+	defer live.Unsubscribe(sub)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // disables response buffering in nginx
+	c.Status(http.StatusOK)
+
+	visible := func(e live.Event) bool {
+		return e.UserID == 0 || e.UserID == user.ID || user.CanViewAll()
+	}
+
+	// Tells the client how long to wait before reconnecting
+	fmt.Fprint(c.Writer, "retry: 3000\n\n")
+	for _, e := range replay {
+		if visible(e) {
+			writeEvent(c, e)
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case e, ok := <-sub.Events():
+			if !ok {
+				// Dropped for falling behind, the client reconnects and catches up
+				return
+			}
+			if !visible(e) {
+				continue
+			}
+			writeEvent(c, e)
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": heartbeat\n\n")
+		}
+		c.Writer.Flush()
+	}
+}
+
+func writeEvent(c *gin.Context, e live.Event) {
+	fmt.Fprintf(c.Writer, "id: %s\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+}
@@ -1,14 +1,22 @@
 package api
 
 import (
+	"errors"
+	"fmt"
+	"html"
+	"io"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"tf-monitor/internal/config"
 	"tf-monitor/internal/model"
 	"tf-monitor/internal/repository"
-	"tf-monitor/internal/service/monitor"
+	"tf-monitor/internal/secret"
+	"tf-monitor/internal/service/auth"
+	"tf-monitor/internal/service/manager"
 	"tf-monitor/internal/service/notify"
 	"tf-monitor/internal/service/scheduler"
 
@@ -16,62 +24,135 @@ import (
 )
 
 type Handler struct {
-	proxyURL string
+	proxyURL      string
+	basePath      string // prefix of the routes, empty at the root
+	authCfg       config.AuthConfig
+	oidc          *auth.OIDCProvider  // nil when SSO is disabled
+	trustedHeader *auth.TrustedHeader // nil when proxy header auth is disabled
+	healthCfg     config.HealthConfig
 }
 
-func NewHandler(proxyURL string) *Handler {
-	return &Handler{proxyURL: proxyURL}
+func NewHandler(proxyURL, basePath string, authCfg config.AuthConfig, healthCfg config.HealthConfig, oidc *auth.OIDCProvider, trustedHeader *auth.TrustedHeader) *Handler {
+	return &Handler{
+		proxyURL:      proxyURL,
+		basePath:      basePath,
+		authCfg:       authCfg,
+		oidc:          oidc,
+		trustedHeader: trustedHeader,
+		healthCfg:     healthCfg,
+	}
 }
 
-func (h *Handler) RegisterRoutes(r *gin.Engine) {
-	api := r.Group("/api")
+// RegisterRoutes adds the API to r, the group of the base path
+func (h *Handler) RegisterRoutes(r *gin.RouterGroup) {
+	r.GET("/healthz", h.Healthz)
+	r.GET("/readyz", h.Readyz)
+
+	public := r.Group("/api")
 	{
+		public.GET("/auth/providers", h.GetAuthProviders)
+		public.POST("/auth/login", h.Login)
+		public.POST("/auth/logout", h.Logout)
+		public.GET("/auth/oidc/login", h.OIDCLogin)
+		public.GET("/auth/oidc/callback", h.OIDCCallback)
+		public.GET("/ack/:token", h.AckLinkPage)
+		public.POST("/ack/:token", h.AckLink)
+	}
+
+	api := r.Group("/api", h.RequireAuth())
+	{
+		api.GET("/auth/me", h.GetCurrentUser)
+		api.PUT("/auth/password", h.ChangePassword)
+
 		api.GET("/monitors", h.ListMonitors)
-		api.POST("/monitors", h.CreateMonitor)
+		api.GET("/monitors/export", h.ExportMonitors)
 		api.GET("/monitors/:id", h.GetMonitor)
-		api.PUT("/monitors/:id", h.UpdateMonitor)
-		api.DELETE("/monitors/:id", h.DeleteMonitor)
-		api.POST("/monitors/:id/toggle", h.ToggleMonitor)
 
 		api.GET("/telegram", h.GetTelegramConfig)
-		api.PUT("/telegram", h.UpdateTelegramConfig)
-		api.POST("/telegram/test", h.TestTelegram)
-
-		api.GET("/proxy", h.GetProxyConfig)
-		api.PUT("/proxy", h.UpdateProxyConfig)
 
 		api.GET("/status", h.GetStatus)
+		api.GET("/notifications", h.ListNotifications)
+		api.GET("/events", h.StreamEvents)
+	}
+
+	editor := api.Group("", h.RequireEditor())
+	{
+		editor.POST("/monitors", h.CreateMonitor)
+		editor.POST("/monitors/import", h.ImportMonitors)
+		editor.PUT("/monitors/:id", h.UpdateMonitor)
+		editor.DELETE("/monitors/:id", h.DeleteMonitor)
+		editor.POST("/monitors/:id/toggle", h.ToggleMonitor)
+		editor.POST("/monitors/:id/check", h.CheckMonitor)
+		editor.POST("/monitors/:id/ack", h.AckMonitor)
+
+		editor.GET("/webhooks", h.ListWebhooks)
+		editor.POST("/webhooks", h.CreateWebhook)
+		editor.GET("/webhooks/:id", h.GetWebhook)
+		editor.PUT("/webhooks/:id", h.UpdateWebhook)
+		editor.DELETE("/webhooks/:id", h.DeleteWebhook)
+		editor.GET("/webhooks/:id/deliveries", h.ListWebhookDeliveries)
+		editor.POST("/webhooks/:id/deliveries/:deliveryId/redeliver", h.RedeliverWebhookDelivery)
+	}
+
+	admin := api.Group("", h.RequireRole(model.RoleAdmin))
+	{
+		admin.PUT("/telegram", h.UpdateTelegramConfig)
+		admin.POST("/telegram/test", h.TestTelegram)
+
+		admin.GET("/proxy", h.GetProxyConfig)
+		admin.PUT("/proxy", h.UpdateProxyConfig)
+
+		admin.GET("/users", h.ListUsers)
+		admin.POST("/users", h.CreateUser)
+		admin.PUT("/users/:id", h.UpdateUser)
+		admin.DELETE("/users/:id", h.DeleteUser)
 	}
 }
 
 type CreateMonitorRequest struct {
-	URLs       string `json:"urls"`
-	Interval   int    `json:"interval"`
-	Duration   int    `json:"duration"`
-	NotifyMode string `json:"notifyMode"`
-	AutoStart  bool   `json:"autoStart"`
+	URLs          string             `json:"urls"`
+	Interval      int                `json:"interval"`
+	Duration      int                `json:"duration"`
+	NotifyMode    string             `json:"notifyMode"`
+	Policy        model.NotifyPolicy `json:"policy"`
+	EscalateAfter int                `json:"escalateAfter"` // minutes, 0 disables escalation
+	Routes        []string           `json:"routes"`        // target groups, the default route when empty
+	AutoStart     bool               `json:"autoStart"`
 }
 
 type MonitorResponse struct {
-	ID            uint       `json:"id"`
-	AppID         string     `json:"appId"`
-	AppName       string     `json:"appName"`
-	IconURL       string     `json:"iconUrl"`
-	TestFlightURL string     `json:"testFlightUrl"`
-	Status        string     `json:"status"`
-	Interval      int        `json:"interval"`
-	Duration      int        `json:"duration"`
-	NotifyMode    string     `json:"notifyMode"`
-	Enabled       bool       `json:"enabled"`
-	LastCheck     *time.Time `json:"lastCheck"`
-	LastError     string     `json:"lastError"`
-	ExpireAt      *time.Time `json:"expireAt"`
-	CreatedAt     time.Time  `json:"createdAt"`
+	ID            uint               `json:"id"`
+	UserID        uint               `json:"userId"`
+	AppID         string             `json:"appId"`
+	AppName       string             `json:"appName"`
+	IconURL       string             `json:"iconUrl"`
+	TestFlightURL string             `json:"testFlightUrl"`
+	Status        string             `json:"status"`
+	Interval      int                `json:"interval"`
+	Duration      int                `json:"duration"`
+	NotifyMode    string             `json:"notifyMode"`
+	Enabled       bool               `json:"enabled"`
+	LastCheck     *time.Time         `json:"lastCheck"`
+	LastError     string             `json:"lastError"`
+	ExpireAt      *time.Time         `json:"expireAt"`
+	SnoozedUntil  *time.Time         `json:"snoozedUntil"`
+	Policy        model.NotifyPolicy `json:"policy"`
+	EscalateAfter int                `json:"escalateAfter"`
+	AlertedAt     *time.Time         `json:"alertedAt"`
+	AckedAt       *time.Time         `json:"ackedAt"`
+	Escalated     bool               `json:"escalated"`
+	Routes        []string           `json:"routes"`
+	CreatedAt     time.Time          `json:"createdAt"`
 }
 
 func toMonitorResponse(m *model.Monitor) MonitorResponse {
+	routes := m.Routes
+	if routes == nil {
+		routes = []string{}
+	}
 	return MonitorResponse{
 		ID:            m.ID,
+		UserID:        m.UserID,
 		AppID:         m.AppID,
 		AppName:       m.AppName,
 		IconURL:       m.IconURL,
@@ -84,13 +165,46 @@ func toMonitorResponse(m *model.Monitor) MonitorResponse {
 		LastCheck:     m.LastCheck,
 		LastError:     m.LastError,
 		ExpireAt:      m.ExpireAt,
+		SnoozedUntil:  m.SnoozedUntil,
+		Policy:        m.Policy,
+		EscalateAfter: m.EscalateAfter,
+		AlertedAt:     m.AlertedAt,
+		AckedAt:       m.AckedAt,
+		Escalated:     m.Escalated,
+		Routes:        routes,
 		CreatedAt:     m.CreatedAt,
 	}
 }
 
+type NotificationResponse struct {
+	ID            uint       `json:"id"`
+	UserID        uint       `json:"userId"`
+	MonitorID     uint       `json:"monitorId"`
+	Channel       string     `json:"channel"`
+	Title         string     `json:"title"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt time.Time  `json:"nextAttemptAt"`
+	LastError     string     `json:"lastError"`
+	SentAt        *time.Time `json:"sentAt"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+// telegramConfigOwner returns whose Telegram settings are addressed, admins
+// may pass ?userId= to manage another user's settings
+func telegramConfigOwner(c *gin.Context) uint {
+	user := currentUser(c)
+	if user.IsAdmin() {
+		if id, err := strconv.ParseUint(c.Query("userId"), 10, 32); err == nil {
+			return uint(id)
+		}
+	}
+	return user.ID
+}
+
 func (h *Handler) ListMonitors(c *gin.Context) {
 	var monitors []model.Monitor
-	repository.GetDB().Order("created_at desc").Find(&monitors)
+	manager.Listable(currentUser(c), c.Query("all") == "true").Order("created_at desc").Find(&monitors)
 
 	result := make([]MonitorResponse, len(monitors))
 	for i, m := range monitors {
@@ -106,80 +220,37 @@ func (h *Handler) CreateMonitor(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := req.Policy.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.EscalateAfter < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "escalateAfter must not be negative"})
+		return
+	}
+	routes, err := manager.ValidateRoutes(currentUser(c).ID, req.Routes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	urls := strings.Split(strings.TrimSpace(req.URLs), "\n")
-	created := []MonitorResponse{}
-	errors := []string{}
-
-	checker := monitor.NewChecker(h.proxyURL)
-
-	for _, url := range urls {
-		url = strings.TrimSpace(url)
-		if url == "" {
-			continue
-		}
-
-		appID, err := monitor.ParseURL(url)
-		if err != nil {
-			errors = append(errors, url+": "+err.Error())
-			continue
-		}
-
-		var existing model.Monitor
-		if repository.GetDB().Where("app_id = ?", appID).First(&existing).Error == nil {
-			errors = append(errors, url+": already exists")
-			continue
-		}
-
-		interval := req.Interval
-		if interval < 10 {
-			interval = 30
-		}
-
-		duration := req.Duration
-
-		notifyMode := model.NotifyMode(req.NotifyMode)
-		if notifyMode == "" {
-			notifyMode = model.NotifyOnce
-		}
-
-		var expireAt *time.Time
-		if duration > 0 {
-			t := time.Now().Add(time.Duration(duration) * time.Hour)
-			expireAt = &t
-		}
-
-		m := model.Monitor{
-			AppID:         appID,
-			TestFlightURL: url,
-			Interval:      interval,
-			Duration:      duration,
-			NotifyMode:    notifyMode,
-			Enabled:       req.AutoStart,
-			ExpireAt:      expireAt,
-		}
-
-		info, err := checker.Check(appID)
-		if err == nil {
-			m.AppName = info.AppName
-			m.IconURL = info.IconURL
-			if info.Available {
-				m.Status = model.StatusAvailable
-			} else {
-				m.Status = model.StatusFull
-			}
-		}
-
-		if err := repository.GetDB().Create(&m).Error; err != nil {
-			errors = append(errors, url+": "+err.Error())
-			continue
-		}
-
-		created = append(created, toMonitorResponse(&m))
-
-		if req.AutoStart {
-			scheduler.GetScheduler().StartJob(m.ID)
-		}
+	monitors, errors := manager.Create(currentUser(c), manager.CreateParams{
+		URLs:          strings.Split(strings.TrimSpace(req.URLs), "\n"),
+		Interval:      req.Interval,
+		Duration:      req.Duration,
+		NotifyMode:    model.NotifyMode(req.NotifyMode),
+		Policy:        req.Policy,
+		EscalateAfter: req.EscalateAfter,
+		Routes:        routes,
+		AutoStart:     req.AutoStart,
+	}, h.proxyURL)
+
+	created := make([]MonitorResponse, len(monitors))
+	for i, m := range monitors {
+		created[i] = toMonitorResponse(&m)
+	}
+	if errors == nil {
+		errors = []string{}
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -191,7 +262,7 @@ func (h *Handler) CreateMonitor(c *gin.Context) {
 func (h *Handler) GetMonitor(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
 	var m model.Monitor
-	if err := repository.GetDB().First(&m, id).Error; err != nil {
+	if err := manager.Readable(currentUser(c)).First(&m, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "monitor not found"})
 		return
 	}
@@ -201,124 +272,359 @@ func (h *Handler) GetMonitor(c *gin.Context) {
 func (h *Handler) UpdateMonitor(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
 	var m model.Monitor
-	if err := repository.GetDB().First(&m, id).Error; err != nil {
+	if err := manager.Writable(currentUser(c)).First(&m, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "monitor not found"})
 		return
 	}
 
 	var req struct {
-		Interval   *int    `json:"interval"`
-		Duration   *int    `json:"duration"`
-		NotifyMode *string `json:"notifyMode"`
+		Interval      *int                `json:"interval"`
+		Duration      *int                `json:"duration"`
+		NotifyMode    *string             `json:"notifyMode"`
+		Policy        *model.NotifyPolicy `json:"policy"`
+		EscalateAfter *int                `json:"escalateAfter"`
+		Routes        *[]string           `json:"routes"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	updates := make(map[string]interface{})
+	// Everything is checked before anything is written
+	params := manager.UpdateParams{
+		Duration:      req.Duration,
+		Policy:        req.Policy,
+		EscalateAfter: req.EscalateAfter,
+	}
 	if req.Interval != nil && *req.Interval >= 10 {
-		updates["interval"] = *req.Interval
-	}
-	if req.Duration != nil {
-		updates["duration"] = *req.Duration
-		if *req.Duration > 0 {
-			expireAt := time.Now().Add(time.Duration(*req.Duration) * time.Hour)
-			updates["expire_at"] = expireAt
-		} else {
-			updates["expire_at"] = nil
-		}
+		params.Interval = req.Interval
 	}
 	if req.NotifyMode != nil {
-		updates["notify_mode"] = *req.NotifyMode
+		mode := model.NotifyMode(*req.NotifyMode)
+		switch mode {
+		case model.NotifyOnce, model.NotifyLoop, model.NotifyOnlyAvailable:
+			params.NotifyMode = &mode
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "notifyMode must be once, loop or only_available"})
+			return
+		}
+	}
+	if req.Policy != nil {
+		if err := req.Policy.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.EscalateAfter != nil && *req.EscalateAfter < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "escalateAfter must not be negative"})
+		return
+	}
+	if req.Routes != nil {
+		routes, err := manager.ValidateRoutes(m.UserID, *req.Routes)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		params.Routes = &routes
 	}
 
-	repository.GetDB().Model(&m).Updates(updates)
-	repository.GetDB().First(&m, id)
+	if err := manager.Update(&m, params); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"data": toMonitorResponse(&m)})
 }
 
 func (h *Handler) DeleteMonitor(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	var m model.Monitor
+	if err := manager.Writable(currentUser(c)).First(&m, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "monitor not found"})
+		return
+	}
 
-	scheduler.GetScheduler().StopJob(uint(id))
-	repository.GetDB().Delete(&model.Monitor{}, id)
+	if err := manager.Delete(&m); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
 }
 
+// ToggleMonitor starts or stops a monitor. A body of {"enabled": bool} sets
+// the state, without one it is flipped.
 func (h *Handler) ToggleMonitor(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
 	var m model.Monitor
-	if err := repository.GetDB().First(&m, id).Error; err != nil {
+	if err := manager.Writable(currentUser(c)).First(&m, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "monitor not found"})
 		return
 	}
 
-	m.Enabled = !m.Enabled
-	if m.Enabled {
-		expireAt := time.Now().Add(time.Duration(m.Duration) * time.Hour)
-		m.ExpireAt = &expireAt
-		m.Notified = false
+	var req struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	enabled := !m.Enabled
+	if req.Enabled != nil {
+		enabled = *req.Enabled
 	}
-	repository.GetDB().Save(&m)
 
-	if m.Enabled {
-		scheduler.GetScheduler().StartJob(m.ID)
-	} else {
-		scheduler.GetScheduler().StopJob(m.ID)
+	if err := manager.SetEnabled(&m, enabled); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": toMonitorResponse(&m)})
+}
+
+// CheckMonitor checks a monitor right away and returns the result, the
+// regular schedule is unaffected
+func (h *Handler) CheckMonitor(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	var m model.Monitor
+	if err := manager.Writable(currentUser(c)).First(&m, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "monitor not found"})
+		return
 	}
 
+	scheduler.GetScheduler().CheckNow(&m)
 	c.JSON(http.StatusOK, gin.H{"data": toMonitorResponse(&m)})
 }
 
+// AckMonitor acknowledges the current alert of a monitor
+func (h *Handler) AckMonitor(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	var m model.Monitor
+	if err := manager.Writable(currentUser(c)).First(&m, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "monitor not found"})
+		return
+	}
+	if m.AlertedAt == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no alert to acknowledge"})
+		return
+	}
+
+	if err := manager.Acknowledge(&m); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": toMonitorResponse(&m)})
+}
+
+// ackLinkMonitor resolves the monitor of a signed acknowledgement link. Links
+// of earlier alerts no longer resolve.
+func ackLinkMonitor(token string) (*model.Monitor, bool) {
+	key, err := repository.SigningKey()
+	if err != nil {
+		return nil, false
+	}
+	id, alertedAt, ok := notify.ParseAckToken(key, token)
+	if !ok {
+		return nil, false
+	}
+
+	var m model.Monitor
+	if err := repository.GetDB().First(&m, id).Error; err != nil {
+		return nil, false
+	}
+	if m.AlertedAt == nil || m.AlertedAt.Unix() != alertedAt {
+		return nil, false
+	}
+	return &m, true
+}
+
+// AckLinkPage asks to confirm an acknowledgement link. Acknowledging takes a
+// POST so link previews and prefetching do not trigger it.
+func (h *Handler) AckLinkPage(c *gin.Context) {
+	m, ok := ackLinkMonitor(c.Param("token"))
+	if !ok {
+		ackPage(c, http.StatusNotFound, "This alert link is invalid or has expired.", false)
+		return
+	}
+	if m.AckedAt != nil {
+		ackPage(c, http.StatusOK, "The alert of "+m.DisplayName()+" is already acknowledged.", false)
+		return
+	}
+	ackPage(c, http.StatusOK, "Acknowledge the alert of "+m.DisplayName()+"?", true)
+}
+
+// AckLink acknowledges the alert a signed link was sent with
+func (h *Handler) AckLink(c *gin.Context) {
+	m, ok := ackLinkMonitor(c.Param("token"))
+	if !ok {
+		ackPage(c, http.StatusNotFound, "This alert link is invalid or has expired.", false)
+		return
+	}
+	if err := manager.Acknowledge(m); err != nil {
+		c.Error(err)
+		ackPage(c, http.StatusInternalServerError, "Failed to acknowledge: "+err.Error(), false)
+		return
+	}
+	ackPage(c, http.StatusOK, "The alert of "+m.DisplayName()+" is acknowledged.", false)
+}
+
+func ackPage(c *gin.Context, status int, text string, confirm bool) {
+	body := `<!DOCTYPE html><html><head><meta charset="utf-8">` +
+		`<meta name="viewport" content="width=device-width, initial-scale=1">` +
+		`<title>TestFlight Monitor</title></head>` +
+		`<body style="font-family: sans-serif; text-align: center; padding: 48px 16px">` +
+		`<p>` + html.EscapeString(text) + `</p>`
+	if confirm {
+		body += `<form method="post"><button type="submit" style="font-size: 16px; padding: 8px 24px">Acknowledge</button></form>`
+	}
+	body += `</body></html>`
+	c.Data(status, "text/html; charset=utf-8", []byte(body))
+}
+
 func (h *Handler) GetTelegramConfig(c *gin.Context) {
 	var cfg model.TelegramConfig
-	repository.GetDB().FirstOrCreate(&cfg, model.TelegramConfig{})
+	repository.GetDB().FirstOrCreate(&cfg, model.TelegramConfig{UserID: telegramConfigOwner(c)})
+
+	targets := cfg.Targets
+	if targets == nil {
+		targets = []model.TelegramTarget{}
+	}
+	botUsers := cfg.BotUsers
+	if botUsers == nil {
+		botUsers = []int64{}
+	}
 	c.JSON(http.StatusOK, gin.H{
-		"botToken": cfg.BotToken,
-		"chatId":   cfg.ChatID,
-		"enabled":  cfg.Enabled,
+		"botToken":       secret.Redact(string(cfg.BotToken)),
+		"targets":        targets,
+		"botUsers":       botUsers,
+		"sendIcon":       cfg.SendIcon,
+		"enabled":        cfg.Enabled,
+		"digestSchedule": cfg.DigestSchedule,
+		"digestTimezone": cfg.DigestTimezone,
+		"lastDigestAt":   cfg.LastDigestAt,
 	})
 }
 
+// telegramTargetsRequest accepts a list of targets, or a single chatId as
+// sent by older clients
+type telegramTargetsRequest struct {
+	Targets []model.TelegramTarget `json:"targets"`
+	ChatID  string                 `json:"chatId"`
+}
+
+func (r *telegramTargetsRequest) targets() ([]model.TelegramTarget, error) {
+	if r.Targets == nil && r.ChatID != "" {
+		return []model.TelegramTarget{{ChatID: r.ChatID}}, nil
+	}
+
+	targets := make([]model.TelegramTarget, 0, len(r.Targets))
+	for _, t := range r.Targets {
+		t.ChatID = strings.TrimSpace(t.ChatID)
+		if t.ChatID == "" {
+			continue
+		}
+		t.Group = strings.TrimSpace(t.Group)
+		if t.Group == model.DefaultRoute {
+			t.Group = ""
+		}
+		if t.ThreadID < 0 {
+			return nil, fmt.Errorf("invalid threadId for chat %s", t.ChatID)
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
 func (h *Handler) UpdateTelegramConfig(c *gin.Context) {
 	var req struct {
-		BotToken string `json:"botToken"`
-		ChatID   string `json:"chatId"`
-		Enabled  bool   `json:"enabled"`
+		telegramTargetsRequest
+		BotToken       string  `json:"botToken"`
+		BotUsers       []int64 `json:"botUsers"`
+		SendIcon       bool    `json:"sendIcon"`
+		Enabled        bool    `json:"enabled"`
+		DigestSchedule string  `json:"digestSchedule"`
+		DigestTimezone string  `json:"digestTimezone"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	targets, err := req.targets()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	var cfg model.TelegramConfig
-	repository.GetDB().FirstOrCreate(&cfg, model.TelegramConfig{})
-	cfg.BotToken = req.BotToken
-	cfg.ChatID = req.ChatID
+	repository.GetDB().FirstOrCreate(&cfg, model.TelegramConfig{UserID: telegramConfigOwner(c)})
+	// An empty or redacted token means keep the stored one
+	if req.BotToken != "" && !secret.IsRedacted(req.BotToken) {
+		cfg.BotToken = model.EncryptedString(req.BotToken)
+	}
+	cfg.Targets = targets
+	cfg.BotUsers = req.BotUsers
+	cfg.SendIcon = req.SendIcon
 	cfg.Enabled = req.Enabled
+	cfg.DigestSchedule = strings.TrimSpace(req.DigestSchedule)
+	cfg.DigestTimezone = strings.TrimSpace(req.DigestTimezone)
+	if err := cfg.ValidateDigest(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	repository.GetDB().Save(&cfg)
 
-	scheduler.GetScheduler().UpdateNotifier(cfg.BotToken, cfg.ChatID)
+	if cfg.Enabled {
+		scheduler.GetScheduler().UpdateNotifier(&cfg)
+	} else {
+		scheduler.GetScheduler().RemoveNotifier(cfg.UserID)
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "saved"})
 }
 
 func (h *Handler) TestTelegram(c *gin.Context) {
 	var req struct {
+		telegramTargetsRequest
 		BotToken string `json:"botToken"`
-		ChatID   string `json:"chatId"`
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil || req.BotToken == "" || req.ChatID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "botToken and chatId required"})
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	targets, err := req.targets()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Fall back to the stored settings for what the request leaves out
+	var cfg model.TelegramConfig
+	repository.GetDB().Where("user_id = ?", telegramConfigOwner(c)).First(&cfg)
+	if req.BotToken == "" || secret.IsRedacted(req.BotToken) {
+		req.BotToken = string(cfg.BotToken)
+	}
+	if len(targets) == 0 {
+		targets = cfg.Targets
+	}
+	if req.BotToken == "" || len(targets) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "botToken and at least one chat required"})
+		return
+	}
+	// The test message goes to digest and escalation targets as well
+	targets = slices.Clone(targets)
+	for i := range targets {
+		targets[i].Digest = false
+		targets[i].Escalation = false
+	}
 
-	notifier := notify.NewTelegramNotifier(req.BotToken, req.ChatID, h.proxyURL)
-	if err := notifier.Send("TestFlight Monitor", "🎉 测试消息发送成功！"); err != nil {
+	notifier := notify.NewTelegramNotifier(req.BotToken, targets, false, h.proxyURL)
+	if _, err := notifier.Send(notify.Message{Title: "TestFlight Monitor", Text: "🎉 测试消息发送成功！"}, nil); err != nil {
+		c.Error(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -327,13 +633,22 @@ func (h *Handler) TestTelegram(c *gin.Context) {
 }
 
 func (h *Handler) GetProxyConfig(c *gin.Context) {
-	var enabled, url model.SystemConfig
-	repository.GetDB().Where("key = ?", "proxy_enabled").First(&enabled)
-	repository.GetDB().Where("key = ?", "proxy_url").First(&url)
+	enabled, err := repository.GetSetting("proxy_enabled")
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	proxyURL, err := repository.GetSetting("proxy_url")
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"enabled": enabled.Value == "true",
-		"url":     url.Value,
+		"enabled": enabled == "true",
+		"url":     secret.RedactURL(proxyURL),
 	})
 }
 
@@ -347,25 +662,72 @@ func (h *Handler) UpdateProxyConfig(c *gin.Context) {
 		return
 	}
 
-	repository.GetDB().Where("key = ?", "proxy_enabled").Assign(model.SystemConfig{
-		Key:   "proxy_enabled",
-		Value: strconv.FormatBool(req.Enabled),
-	}).FirstOrCreate(&model.SystemConfig{})
+	existing, err := repository.GetSetting("proxy_url")
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	proxyURL := secret.RestoreURL(req.URL, existing)
 
-	repository.GetDB().Where("key = ?", "proxy_url").Assign(model.SystemConfig{
-		Key:   "proxy_url",
-		Value: req.URL,
-	}).FirstOrCreate(&model.SystemConfig{})
+	if err := repository.SetSetting("proxy_enabled", strconv.FormatBool(req.Enabled)); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := repository.SetSetting("proxy_url", proxyURL); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
-	proxyURL := ""
-	if req.Enabled {
-		proxyURL = req.URL
+	if !req.Enabled {
+		proxyURL = ""
 	}
 	scheduler.GetScheduler().Init(proxyURL)
 
 	c.JSON(http.StatusOK, gin.H{"message": "saved"})
 }
 
+// ListNotifications shows the outbox, newest first. Scoped like the monitor
+// list and filterable by ?status= and ?monitorId=.
+func (h *Handler) ListNotifications(c *gin.Context) {
+	query := manager.Listable(currentUser(c), c.Query("all") == "true")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if monitorID := c.Query("monitorId"); monitorID != "" {
+		query = query.Where("monitor_id = ?", monitorID)
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	var notifications []model.Notification
+	query.Order("created_at desc").Limit(limit).Find(&notifications)
+
+	result := make([]NotificationResponse, len(notifications))
+	for i, n := range notifications {
+		result[i] = NotificationResponse{
+			ID:            n.ID,
+			UserID:        n.UserID,
+			MonitorID:     n.MonitorID,
+			Channel:       n.Channel,
+			Title:         n.Title,
+			Status:        string(n.Status),
+			Attempts:      n.Attempts,
+			NextAttemptAt: n.NextAttemptAt,
+			LastError:     n.LastError,
+			SentAt:        n.SentAt,
+			CreatedAt:     n.CreatedAt,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
 func (h *Handler) GetStatus(c *gin.Context) {
 	sched := scheduler.GetScheduler()
 	c.JSON(http.StatusOK, gin.H{
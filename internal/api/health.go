@@ -0,0 +1,89 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"tf-monitor/internal/repository"
+	"tf-monitor/internal/service/scheduler"
+
+	"github.com/gin-gonic/gin"
+)
+
+// healthCheck is the result of one readiness check
+type healthCheck struct {
+	Status string `json:"status"` // "ok" or "fail"
+	Detail string `json:"detail,omitempty"`
+}
+
+func checkResult(err error) healthCheck {
+	if err != nil {
+		return healthCheck{Status: "fail", Detail: err.Error()}
+	}
+	return healthCheck{Status: "ok"}
+}
+
+// Healthz reports that the process is up
+func (h *Handler) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz reports whether the service is doing its job: the database answers,
+// the scheduler runs, checks succeed and alerts can be sent
+func (h *Handler) Readyz(c *gin.Context) {
+	sched := scheduler.GetScheduler()
+	checks := map[string]healthCheck{
+		"database":  checkResult(pingDatabase(c.Request.Context())),
+		"scheduler": checkResult(checkScheduler(sched)),
+		"checks":    checkResult(h.checkMonitoring(sched)),
+		"notifier":  checkResult(checkNotifier(sched)),
+	}
+
+	status, code := "ok", http.StatusOK
+	for _, check := range checks {
+		if check.Status != "ok" {
+			status, code = "unavailable", http.StatusServiceUnavailable
+		}
+	}
+	c.JSON(code, gin.H{"status": status, "checks": checks})
+}
+
+func pingDatabase(ctx context.Context) error {
+	db, err := repository.GetDB().DB()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	return db.PingContext(ctx)
+}
+
+func checkScheduler(sched *scheduler.Scheduler) error {
+	if !sched.IsRunning() {
+		return fmt.Errorf("scheduler is not running")
+	}
+	return nil
+}
+
+// checkMonitoring fails when no active monitor was checked successfully
+// within the threshold, e.g. because TestFlight or the proxy is unreachable
+func (h *Handler) checkMonitoring(sched *scheduler.Scheduler) error {
+	last, ok := sched.LastSuccessfulCheck()
+	if !ok || h.healthCfg.CheckThreshold <= 0 {
+		return nil
+	}
+	if since := time.Since(last); since > time.Duration(h.healthCfg.CheckThreshold)*time.Second {
+		return fmt.Errorf("no successful check for %s", since.Truncate(time.Second))
+	}
+	return nil
+}
+
+// checkNotifier fails when monitors are running but nobody can be alerted
+func checkNotifier(sched *scheduler.Scheduler) error {
+	if sched.GetActiveJobCount() > 0 && sched.NotifierCount() == 0 {
+		return fmt.Errorf("monitors are active but no notifications are configured")
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"tf-monitor/internal/model"
+	"tf-monitor/internal/service/manager"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxImportSize    = 1 << 20 // bytes
+	maxImportEntries = 1000
+)
+
+// ExportMonitors returns the settings of the monitors in the user's list as
+// a file, in the format of ?format=json, csv or yaml
+func (h *Handler) ExportMonitors(c *gin.Context) {
+	format := c.DefaultQuery("format", manager.FormatJSON)
+	contentType := manager.ContentType(format)
+	if contentType == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json, csv or yaml"})
+		return
+	}
+
+	var monitors []model.Monitor
+	manager.Listable(currentUser(c), c.Query("all") == "true").Order("created_at asc").Find(&monitors)
+
+	var buf bytes.Buffer
+	if err := manager.EncodeEntries(&buf, format, manager.Export(monitors)); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="tf-monitor-monitors.`+format+`"`)
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+// ImportMonitors adds the monitors of the file in the request body, in the
+// format of ?format=. Apps the user already monitors are handled as
+// ?conflict= says, skip by default. With ?dryRun=true nothing is written.
+func (h *Handler) ImportMonitors(c *gin.Context) {
+	format := c.DefaultQuery("format", manager.FormatJSON)
+	if manager.ContentType(format) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json, csv or yaml"})
+		return
+	}
+	conflict := c.DefaultQuery("conflict", manager.ConflictSkip)
+	if !manager.ValidConflict(conflict) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "conflict must be skip, overwrite or rename"})
+		return
+	}
+	dryRun, _ := strconv.ParseBool(c.Query("dryRun"))
+
+	entries, err := manager.DecodeEntries(http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize), format)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file is larger than 1 MB"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(entries) > maxImportEntries {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at most " + strconv.Itoa(maxImportEntries) + " monitors can be imported at once"})
+		return
+	}
+
+	results, err := manager.Import(currentUser(c), entries, conflict, dryRun)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if results == nil {
+		results = []manager.ImportResult{}
+	}
+
+	summary := map[string]int{
+		manager.ImportCreate: 0,
+		manager.ImportUpdate: 0,
+		manager.ImportSkip:   0,
+		manager.ImportRename: 0,
+		manager.ImportError:  0,
+	}
+	for _, r := range results {
+		summary[r.Action]++
+	}
+	c.JSON(http.StatusOK, gin.H{"data": results, "summary": summary, "dryRun": dryRun})
+}
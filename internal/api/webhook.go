@@ -0,0 +1,295 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"tf-monitor/internal/model"
+	"tf-monitor/internal/repository"
+	"tf-monitor/internal/secret"
+	"tf-monitor/internal/service/webhook"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type WebhookResponse struct {
+	ID          uint      `json:"id"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"secret"` // redacted except in the response to its creation
+	Events      []string  `json:"events"`
+	Active      bool      `json:"active"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+func toWebhookResponse(w *model.Webhook) WebhookResponse {
+	return WebhookResponse{
+		ID:          w.ID,
+		URL:         w.URL,
+		Secret:      secret.Redact(string(w.Secret)),
+		Events:      w.Events,
+		Active:      w.Active,
+		Description: w.Description,
+		CreatedAt:   w.CreatedAt,
+	}
+}
+
+type WebhookDeliveryResponse struct {
+	ID             uint       `json:"id"`
+	WebhookID      uint       `json:"webhookId"`
+	MonitorID      uint       `json:"monitorId"`
+	GUID           string     `json:"guid"`
+	Event          string     `json:"event"`
+	Payload        string     `json:"payload"`
+	Redelivery     bool       `json:"redelivery"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"nextAttemptAt"`
+	ResponseStatus int        `json:"responseStatus"`
+	LastError      string     `json:"lastError"`
+	DeliveredAt    *time.Time `json:"deliveredAt"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+func toWebhookDeliveryResponse(d *model.WebhookDelivery) WebhookDeliveryResponse {
+	return WebhookDeliveryResponse{
+		ID:             d.ID,
+		WebhookID:      d.WebhookID,
+		MonitorID:      d.MonitorID,
+		GUID:           d.GUID,
+		Event:          d.Event,
+		Payload:        d.Payload,
+		Redelivery:     d.Redelivery,
+		Status:         string(d.Status),
+		Attempts:       d.Attempts,
+		NextAttemptAt:  d.NextAttemptAt,
+		ResponseStatus: d.ResponseStatus,
+		LastError:      d.LastError,
+		DeliveredAt:    d.DeliveredAt,
+		CreatedAt:      d.CreatedAt,
+	}
+}
+
+// ownWebhooks limits webhook queries to those of the current user
+func ownWebhooks(c *gin.Context) *gorm.DB {
+	return repository.GetDB().Where("user_id = ?", currentUser(c).ID)
+}
+
+// findWebhook loads the webhook named by the id parameter, it responds with
+// 404 and returns false when the user has none such
+func findWebhook(c *gin.Context, w *model.Webhook) bool {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err := ownWebhooks(c).First(w, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "webhook not found"})
+		return false
+	}
+	return true
+}
+
+func (h *Handler) ListWebhooks(c *gin.Context) {
+	var webhooks []model.Webhook
+	ownWebhooks(c).Order("created_at desc").Find(&webhooks)
+
+	result := make([]WebhookResponse, len(webhooks))
+	for i := range webhooks {
+		result[i] = toWebhookResponse(&webhooks[i])
+	}
+	c.JSON(http.StatusOK, gin.H{"data": result, "events": webhook.EventTypes})
+}
+
+func (h *Handler) CreateWebhook(c *gin.Context) {
+	var req struct {
+		URL         string   `json:"url"`
+		Secret      string   `json:"secret"` // generated when empty
+		Events      []string `json:"events"`
+		Active      *bool    `json:"active"`
+		Description string   `json:"description"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.URL = strings.TrimSpace(req.URL)
+	if err := webhook.ValidateURL(req.URL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := webhook.ValidateEvents(req.Events); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Secret == "" {
+		generated, err := webhook.GenerateSecret()
+		if err != nil {
+			c.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		req.Secret = generated
+	}
+
+	w := model.Webhook{
+		UserID:      currentUser(c).ID,
+		URL:         req.URL,
+		Secret:      model.EncryptedString(req.Secret),
+		Events:      req.Events,
+		Active:      req.Active == nil || *req.Active,
+		Description: strings.TrimSpace(req.Description),
+	}
+	if err := repository.GetDB().Create(&w).Error; err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The secret is shown once, to be configured at the receiver
+	resp := toWebhookResponse(&w)
+	resp.Secret = req.Secret
+	c.JSON(http.StatusOK, gin.H{"data": resp})
+}
+
+func (h *Handler) GetWebhook(c *gin.Context) {
+	var w model.Webhook
+	if !findWebhook(c, &w) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": toWebhookResponse(&w)})
+}
+
+func (h *Handler) UpdateWebhook(c *gin.Context) {
+	var w model.Webhook
+	if !findWebhook(c, &w) {
+		return
+	}
+
+	var req struct {
+		URL         *string   `json:"url"`
+		Secret      *string   `json:"secret"` // empty or redacted keeps the stored one
+		Events      *[]string `json:"events"`
+		Active      *bool     `json:"active"`
+		Description *string   `json:"description"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.URL != nil {
+		url := strings.TrimSpace(*req.URL)
+		if err := webhook.ValidateURL(url); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		updates["url"] = url
+	}
+	if req.Events != nil {
+		if err := webhook.ValidateEvents(*req.Events); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		w.Events = *req.Events
+	}
+	if req.Secret != nil && *req.Secret != "" && !secret.IsRedacted(*req.Secret) {
+		updates["secret"] = model.EncryptedString(*req.Secret)
+	}
+	if req.Active != nil {
+		updates["active"] = *req.Active
+	}
+	if req.Description != nil {
+		updates["description"] = strings.TrimSpace(*req.Description)
+	}
+
+	err := repository.GetDB().Transaction(func(tx *gorm.DB) error {
+		if req.Events != nil {
+			if err := tx.Model(&w).Select("events").Updates(&w).Error; err != nil {
+				return err
+			}
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		return tx.Model(&w).Updates(updates).Error
+	})
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": toWebhookResponse(&w)})
+}
+
+func (h *Handler) DeleteWebhook(c *gin.Context) {
+	var w model.Webhook
+	if !findWebhook(c, &w) {
+		return
+	}
+
+	err := repository.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("webhook_id = ?", w.ID).Delete(&model.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&w).Error
+	})
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+// ListWebhookDeliveries returns the delivery history of a webhook, newest
+// first, optionally filtered by status
+func (h *Handler) ListWebhookDeliveries(c *gin.Context) {
+	var w model.Webhook
+	if !findWebhook(c, &w) {
+		return
+	}
+
+	query := repository.GetDB().Where("webhook_id = ?", w.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	var deliveries []model.WebhookDelivery
+	query.Order("created_at desc").Limit(limit).Find(&deliveries)
+
+	result := make([]WebhookDeliveryResponse, len(deliveries))
+	for i := range deliveries {
+		result[i] = toWebhookDeliveryResponse(&deliveries[i])
+	}
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// RedeliverWebhookDelivery sends the event of a past delivery again
+func (h *Handler) RedeliverWebhookDelivery(c *gin.Context) {
+	var w model.Webhook
+	if !findWebhook(c, &w) {
+		return
+	}
+
+	deliveryID, _ := strconv.ParseUint(c.Param("deliveryId"), 10, 32)
+	var d model.WebhookDelivery
+	if err := repository.GetDB().Where("webhook_id = ?", w.ID).First(&d, deliveryID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "delivery not found"})
+		return
+	}
+
+	again, err := webhook.Redeliver(&d)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": toWebhookDeliveryResponse(again)})
+}
@@ -0,0 +1,349 @@
+// Package cli implements the subcommands of tf-monitor that manage monitors
+// from the shell. They work on the local database, or on a running server
+// through its HTTP API when a server URL is given.
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"tf-monitor/internal/model"
+)
+
+const usage = `Usage: tf-monitor [command] [flags] [args]
+
+Without a command the server is started.
+
+Commands:
+  serve                      start the server
+  list [-all]                list monitors, -all includes those of other users
+  add [flags] <url|code>...  add monitors
+      -interval seconds      check interval (default 60)
+      -duration hours        pause after this many hours, 0 never (default 0)
+      -notify mode           once, loop or only_available (default once)
+      -paused                add without starting
+  pause <id>...              stop checking monitors
+  resume <id>...             start checking monitors
+  delete <id>...             delete monitors
+  check <id>...              check monitors now
+  check [-fail-if cond] <url|code>...
+                             check links once, without database or server,
+                             exit with 1 when cond holds: none (no link has
+                             an open slot, the default) or any
+  settings [key=value...]    show or change the proxy and Telegram settings
+  healthcheck                exit with 1 unless the server on this host is
+                             ready, for container health checks
+
+Flags of every command:
+  -server url    manage the server at url through its API, e.g.
+                 https://example.com/tf, instead of the local database
+                 (TF_MONITOR_SERVER)
+  -token token   session token for -server (TF_MONITOR_TOKEN)
+  -user name     user to act as on the local database (default ADMIN_USERNAME)
+  -o format      output format, table or json (default table)
+`
+
+// Exit codes of Run
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// errFailed reports that a command failed for some of its arguments, the
+// details were printed already
+var errFailed = errors.New("failed")
+
+// Monitor is a monitor as printed by the commands
+type Monitor struct {
+	ID            uint       `json:"id"`
+	AppID         string     `json:"appId"`
+	AppName       string     `json:"appName"`
+	TestFlightURL string     `json:"testFlightUrl"`
+	Status        string     `json:"status"`
+	Interval      int        `json:"interval"`
+	Duration      int        `json:"duration"`
+	NotifyMode    string     `json:"notifyMode"`
+	Enabled       bool       `json:"enabled"`
+	LastCheck     *time.Time `json:"lastCheck"`
+	LastError     string     `json:"lastError"`
+	ExpireAt      *time.Time `json:"expireAt"`
+}
+
+// AddRequest describes monitors to add
+type AddRequest struct {
+	URLs       []string
+	Interval   int
+	Duration   int
+	NotifyMode string
+	Paused     bool
+}
+
+// client runs the commands against the local database or a remote server
+type client interface {
+	List(all bool) ([]Monitor, error)
+	// Add returns the monitors created and a message for each URL that was not
+	Add(req AddRequest) ([]Monitor, []string, error)
+	SetEnabled(id uint, enabled bool) (*Monitor, error)
+	Delete(id uint) (*Monitor, error)
+	Check(id uint) (*Monitor, error)
+	Settings() (*Settings, error)
+	SaveProxy(p *ProxySettings) error
+	SaveTelegram(t *TelegramSettings) error
+}
+
+// IsCommand reports whether name is a subcommand handled by Run
+func IsCommand(name string) bool {
+	switch name {
+	case "list", "add", "pause", "resume", "delete", "check", "settings", "healthcheck", "help", "-h", "-help", "--help":
+		return true
+	}
+	return false
+}
+
+// Run executes the subcommand in args and returns the exit code
+func Run(args []string) int {
+	command := args[0]
+	if command == "help" || command == "-h" || command == "-help" || command == "--help" {
+		fmt.Print(usage)
+		return exitOK
+	}
+
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	server := fs.String("server", os.Getenv("TF_MONITOR_SERVER"), "")
+	token := fs.String("token", os.Getenv("TF_MONITOR_TOKEN"), "")
+	user := fs.String("user", "", "")
+	output := fs.String("o", "table", "")
+	all := fs.Bool("all", false, "")
+	interval := fs.Int("interval", 60, "")
+	duration := fs.Int("duration", 0, "")
+	notifyMode := fs.String("notify", "once", "")
+	paused := fs.Bool("paused", false, "")
+	failIf := fs.String("fail-if", failIfNone, "")
+
+	// Flags may follow the arguments as well
+	var positional []string
+	for rest := args[1:]; ; rest = fs.Args()[1:] {
+		if err := fs.Parse(rest); err != nil {
+			return usageError(err.Error())
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+	}
+	if *output != "table" && *output != "json" {
+		return usageError("-o must be table or json")
+	}
+
+	if command == "healthcheck" {
+		if len(positional) > 0 {
+			return usageError("healthcheck takes no arguments")
+		}
+		return healthcheck()
+	}
+
+	out := &printer{w: os.Stdout, json: *output == "json"}
+	if command == "check" && len(positional) > 0 && !isMonitorIDs(positional) {
+		if *failIf != failIfNone && *failIf != failIfAny {
+			return usageError("-fail-if must be none or any")
+		}
+		return probe(out, joinURLs(positional), *failIf)
+	}
+
+	var c client
+	if *server != "" {
+		if *token == "" {
+			return usageError("-server requires -token or TF_MONITOR_TOKEN")
+		}
+		c = newRemoteClient(*server, *token)
+	} else {
+		local, err := newLocalClient(*user)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return exitError
+		}
+		c = local
+	}
+
+	var err error
+	switch command {
+	case "list":
+		err = list(c, out, *all)
+	case "add":
+		if len(positional) == 0 {
+			return usageError("add requires at least one URL or invite code")
+		}
+		switch model.NotifyMode(*notifyMode) {
+		case model.NotifyOnce, model.NotifyLoop, model.NotifyOnlyAvailable:
+		default:
+			return usageError("-notify must be once, loop or only_available")
+		}
+		err = add(c, out, AddRequest{
+			URLs:       joinURLs(positional),
+			Interval:   *interval,
+			Duration:   *duration,
+			NotifyMode: *notifyMode,
+			Paused:     *paused,
+		})
+	case "pause", "resume", "delete", "check":
+		ids, parseErr := parseIDs(positional)
+		if parseErr != nil {
+			return usageError(parseErr.Error())
+		}
+		err = each(c, out, command, ids)
+	case "settings":
+		err = settings(c, out, positional)
+	}
+
+	if err != nil {
+		if err != errFailed {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+		}
+		return exitError
+	}
+	return exitOK
+}
+
+func usageError(msg string) int {
+	fmt.Fprintf(os.Stderr, "Error: %s\n\n%s", msg, usage)
+	return exitUsage
+}
+
+// joinURLs turns bare invite codes into join links
+func joinURLs(args []string) []string {
+	urls := make([]string, len(args))
+	for i, arg := range args {
+		if !strings.Contains(arg, "/") {
+			arg = "https://testflight.apple.com/join/" + arg
+		}
+		urls[i] = arg
+	}
+	return urls
+}
+
+func parseIDs(args []string) ([]uint, error) {
+	if len(args) == 0 {
+		return nil, errors.New("at least one monitor id is required")
+	}
+	ids := make([]uint, len(args))
+	for i, arg := range args {
+		id, err := strconv.ParseUint(strings.TrimPrefix(arg, "#"), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid monitor id %q", arg)
+		}
+		ids[i] = uint(id)
+	}
+	return ids, nil
+}
+
+func list(c client, out *printer, all bool) error {
+	monitors, err := c.List(all)
+	if err != nil {
+		return err
+	}
+	return out.monitors(monitors)
+}
+
+func add(c client, out *printer, req AddRequest) error {
+	created, errs, err := c.Add(req)
+	if err != nil {
+		return err
+	}
+	for _, e := range errs {
+		fmt.Fprintln(os.Stderr, "Failed:", e)
+	}
+	if err := out.monitors(created); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errFailed
+	}
+	return nil
+}
+
+// each runs a command on every monitor in ids and prints those it succeeded
+// for, it carries on past failures
+func each(c client, out *printer, command string, ids []uint) error {
+	var done []Monitor
+	failed := false
+	for _, id := range ids {
+		var m *Monitor
+		var err error
+		switch command {
+		case "pause":
+			m, err = c.SetEnabled(id, false)
+		case "resume":
+			m, err = c.SetEnabled(id, true)
+		case "delete":
+			m, err = c.Delete(id)
+		case "check":
+			m, err = c.Check(id)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed: #%d: %v\n", id, err)
+			failed = true
+			continue
+		}
+		done = append(done, *m)
+	}
+
+	if err := out.monitors(done); err != nil {
+		return err
+	}
+	if failed {
+		return errFailed
+	}
+	return nil
+}
+
+// printer writes results as a table or as JSON
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+func (p *printer) writeJSON(v any) error {
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(v)
+}
+
+func (p *printer) monitors(monitors []Monitor) error {
+	if monitors == nil {
+		monitors = []Monitor{}
+	}
+	if p.json {
+		return p.writeJSON(monitors)
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tAPP\tSTATUS\tSTATE\tINTERVAL\tLAST CHECK\tURL")
+	for _, m := range monitors {
+		name := m.AppName
+		if name == "" {
+			name = m.AppID
+		}
+		state := "running"
+		if !m.Enabled {
+			state = "paused"
+		}
+		lastCheck := "-"
+		if m.LastCheck != nil {
+			lastCheck = m.LastCheck.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%ds\t%s\t%s\n",
+			m.ID, name, m.Status, state, m.Interval, lastCheck, m.TestFlightURL)
+	}
+	return tw.Flush()
+}
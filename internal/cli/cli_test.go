@@ -0,0 +1,183 @@
+package cli
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseIDs(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    []uint
+		wantErr bool
+	}{
+		{[]string{"1"}, []uint{1}, false},
+		{[]string{"#3", "12"}, []uint{3, 12}, false},
+		{nil, nil, true},
+		{[]string{"1", "abc"}, nil, true},
+		{[]string{"-1"}, nil, true},
+		{[]string{"4294967296"}, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseIDs(tt.args)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseIDs(%q) = %v, %v", tt.args, got, err)
+		}
+	}
+}
+
+func TestIsMonitorIDs(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"1", "#2"}, true},
+		{[]string{"1", "abcd1234"}, false},
+		{[]string{"https://testflight.apple.com/join/abcd1234"}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := isMonitorIDs(tt.args); got != tt.want {
+			t.Errorf("isMonitorIDs(%q) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestJoinURLs(t *testing.T) {
+	got := joinURLs([]string{"abcd1234", "https://testflight.apple.com/join/efgh5678"})
+	want := []string{"https://testflight.apple.com/join/abcd1234", "https://testflight.apple.com/join/efgh5678"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("joinURLs = %q, want %q", got, want)
+	}
+}
+
+func TestParseUserIDs(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []int64
+		wantErr bool
+	}{
+		{"", []int64{}, false},
+		{"123, 456,,", []int64{123, 456}, false},
+		{"-1001234567890", []int64{-1001234567890}, false},
+		{"123,@alice", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseUserIDs(tt.value)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseUserIDs(%q) = %v, %v", tt.value, got, err)
+		}
+	}
+}
+
+// fakeServer answers the API calls of the commands, monitor 1 exists and
+// monitor 2 does not
+func fakeServer(t *testing.T) string {
+	t.Helper()
+	monitor := map[string]any{"data": Monitor{ID: 1, AppID: "abcd1234", Status: "full", Enabled: true}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/monitors", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"data": []Monitor{{ID: 1}}})
+	})
+	mux.HandleFunc("POST /api/monitors/1/toggle", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(monitor)
+	})
+	mux.HandleFunc("POST /api/monitors/2/toggle", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "monitor not found"})
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestRunExitCodes(t *testing.T) {
+	server := fakeServer(t)
+	remote := func(args ...string) []string {
+		return append(args, "-server", server, "-token", "token")
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"help", []string{"help"}, exitOK},
+		{"list", remote("list"), exitOK},
+		{"list as JSON", remote("list", "-o", "json"), exitOK},
+		{"pause", remote("pause", "1"), exitOK},
+		{"flags after arguments", []string{"pause", "1", "-server", server, "-token", "token", "-o", "json"}, exitOK},
+		{"pause a missing monitor", remote("pause", "1", "2"), exitError},
+		{"wrong token", []string{"list", "-server", server, "-token", "other"}, exitError},
+
+		{"unknown flag", remote("list", "-verbose"), exitUsage},
+		{"unknown output", remote("list", "-o", "xml"), exitUsage},
+		{"server without token", []string{"list", "-server", server, "-token", ""}, exitUsage},
+		{"pause without ID", remote("pause"), exitUsage},
+		{"pause a link", remote("pause", "abcd1234"), exitUsage},
+		{"add without link", remote("add"), exitUsage},
+		{"add with unknown mode", remote("add", "-notify", "sometimes", "abcd1234"), exitUsage},
+		{"check with unknown condition", []string{"check", "-fail-if", "all", "abcd1234"}, exitUsage},
+		{"healthcheck with arguments", []string{"healthcheck", "now"}, exitUsage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TF_MONITOR_SERVER", "")
+			t.Setenv("TF_MONITOR_TOKEN", "")
+			if got := Run(tt.args); got != tt.want {
+				t.Errorf("Run(%q) = %d, want %d", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsCommand(t *testing.T) {
+	for _, name := range []string{"list", "add", "pause", "resume", "delete", "check", "settings", "healthcheck", "help", "--help"} {
+		if !IsCommand(name) {
+			t.Errorf("IsCommand(%q) = false", name)
+		}
+	}
+	for _, name := range []string{"serve", "start", ""} {
+		if IsCommand(name) {
+			t.Errorf("IsCommand(%q) = true", name)
+		}
+	}
+}
+
+func TestSettingsSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		admin   bool
+		key     string
+		value   string
+		wantErr bool
+	}{
+		{"proxy as admin", true, "proxy.enabled", "true", false},
+		{"proxy as user", false, "proxy.url", "http://proxy:8080", true},
+		{"boolean", false, "telegram.sendIcon", "false", false},
+		{"not a boolean", false, "telegram.enabled", "yes please", true},
+		{"bot users", false, "telegram.botUsers", "1,2", false},
+		{"invalid bot users", false, "telegram.botUsers", "1,bob", true},
+		{"unknown key", false, "telegram.color", "blue", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Settings{}
+			if tt.admin {
+				s.Proxy = &ProxySettings{}
+			}
+			if err := s.set(tt.key, tt.value); (err != nil) != tt.wantErr {
+				t.Errorf("set(%q, %q) = %v", tt.key, tt.value, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"tf-monitor/internal/config"
+)
+
+const healthcheckTimeout = 4 * time.Second
+
+// healthcheck asks the server running on this host whether it is ready, for
+// the health check of the container image. The address is built from the
+// configuration the server reads, so BASE_PATH is normalized the same way.
+func healthcheck() int {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return exitError
+	}
+
+	url := "http://127.0.0.1:" + cfg.Server.Port + cfg.Server.BasePath + "/readyz"
+	client := &http.Client{Timeout: healthcheckTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return exitError
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "Error: %s responded with %s\n", url, resp.Status)
+		return exitError
+	}
+	return exitOK
+}
@@ -0,0 +1,271 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"tf-monitor/internal/config"
+	"tf-monitor/internal/logging"
+	"tf-monitor/internal/model"
+	"tf-monitor/internal/repository"
+	"tf-monitor/internal/secret"
+	"tf-monitor/internal/service/events"
+	"tf-monitor/internal/service/manager"
+	"tf-monitor/internal/service/scheduler"
+	"tf-monitor/internal/service/webhook"
+)
+
+var errPermission = errors.New("permission denied")
+
+// localClient works on the database directly, with the same services the
+// server uses. Alerts and webhook deliveries it causes are queued in the
+// database and sent by the server.
+type localClient struct {
+	user     *model.User
+	proxyURL string
+}
+
+// newLocalClient opens the database of the server configuration and acts as
+// the named user, or as the configured admin when username is empty
+func newLocalClient(username string) (*localClient, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	// Only errors are logged, the output of the commands stays readable
+	cfg.Log.Level = "error"
+	if err := logging.Setup(cfg.Log, os.Stderr); err != nil {
+		return nil, err
+	}
+	if err := secret.Configure(cfg.Secret.Key, cfg.Secret.PreviousKeys); err != nil {
+		return nil, err
+	}
+	// A typo in DB_PATH must not leave an empty database behind
+	if _, err := os.Stat(cfg.Database.Path); err != nil {
+		return nil, fmt.Errorf("database %s not found, set DB_PATH or use -server", cfg.Database.Path)
+	}
+	if err := repository.InitDB(cfg.Database.Path); err != nil {
+		return nil, err
+	}
+
+	if username == "" {
+		username = cfg.Auth.AdminUsername
+	}
+	var user model.User
+	if err := repository.GetDB().Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, fmt.Errorf("user %q not found", username)
+	}
+
+	proxyURL := ""
+	if cfg.Proxy.Enabled {
+		proxyURL = cfg.Proxy.URL
+	}
+
+	// Checks run through the scheduler, so alerts follow the same policy as
+	// those of the server and are queued for the same chats. It is never
+	// started, a running server starts jobs for the monitors added or resumed
+	// here within a minute.
+	sched := scheduler.GetScheduler()
+	sched.Init(proxyURL)
+	policy := model.NotifyPolicy{
+		MinRepeatInterval: cfg.Notify.MinRepeatInterval,
+		MaxPerHour:        cfg.Notify.MaxPerHour,
+		QuietHours:        cfg.Notify.QuietHours,
+		QuietMode:         model.QuietMode(cfg.Notify.QuietMode),
+		Timezone:          cfg.Notify.Timezone,
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid notification policy: %w", err)
+	}
+	sched.SetNotifyPolicy(policy, time.Duration(cfg.Notify.DedupWindow)*time.Second)
+	sched.SetPublicURL(cfg.Server.PublicURL)
+	sched.SetBotToken(cfg.Bot.Token)
+	if cfg.Server.PublicURL != "" {
+		if _, err := repository.SigningKey(); err != nil {
+			return nil, err
+		}
+	}
+	webhook.Listen(events.GetBus())
+
+	// Admins check monitors of other users too, their alerts go to the chats
+	// of the owner
+	var telegramCfgs []model.TelegramConfig
+	if err := repository.GetDB().Where("enabled = ?", true).Find(&telegramCfgs).Error; err != nil {
+		return nil, err
+	}
+	for i := range telegramCfgs {
+		sched.UpdateNotifier(&telegramCfgs[i])
+	}
+
+	return &localClient{user: &user, proxyURL: proxyURL}, nil
+}
+
+// writable loads a monitor the user may change
+func (c *localClient) writable(id uint) (*model.Monitor, error) {
+	if !c.user.CanEdit() {
+		return nil, errPermission
+	}
+	var m model.Monitor
+	if err := manager.Writable(c.user).First(&m, id).Error; err != nil {
+		return nil, errors.New("monitor not found")
+	}
+	return &m, nil
+}
+
+func (c *localClient) List(all bool) ([]Monitor, error) {
+	var monitors []model.Monitor
+	if err := manager.Listable(c.user, all).Order("created_at desc").Find(&monitors).Error; err != nil {
+		return nil, err
+	}
+	result := make([]Monitor, len(monitors))
+	for i := range monitors {
+		result[i] = toMonitor(&monitors[i])
+	}
+	return result, nil
+}
+
+func (c *localClient) Add(req AddRequest) ([]Monitor, []string, error) {
+	if !c.user.CanEdit() {
+		return nil, nil, errPermission
+	}
+	created, errs := manager.Create(c.user, manager.CreateParams{
+		URLs:       req.URLs,
+		Interval:   req.Interval,
+		Duration:   req.Duration,
+		NotifyMode: model.NotifyMode(req.NotifyMode),
+		AutoStart:  !req.Paused,
+	}, c.proxyURL)
+
+	result := make([]Monitor, len(created))
+	for i := range created {
+		result[i] = toMonitor(&created[i])
+	}
+	return result, errs, nil
+}
+
+func (c *localClient) SetEnabled(id uint, enabled bool) (*Monitor, error) {
+	m, err := c.writable(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := manager.SetEnabled(m, enabled); err != nil {
+		return nil, err
+	}
+	result := toMonitor(m)
+	return &result, nil
+}
+
+func (c *localClient) Delete(id uint) (*Monitor, error) {
+	m, err := c.writable(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := manager.Delete(m); err != nil {
+		return nil, err
+	}
+	result := toMonitor(m)
+	return &result, nil
+}
+
+func (c *localClient) Check(id uint) (*Monitor, error) {
+	m, err := c.writable(id)
+	if err != nil {
+		return nil, err
+	}
+	scheduler.GetScheduler().CheckNow(m)
+	result := toMonitor(m)
+	return &result, nil
+}
+
+func (c *localClient) Settings() (*Settings, error) {
+	s := &Settings{}
+	if c.user.IsAdmin() {
+		enabled, err := repository.GetSetting("proxy_enabled")
+		if err != nil {
+			return nil, err
+		}
+		proxyURL, err := repository.GetSetting("proxy_url")
+		if err != nil {
+			return nil, err
+		}
+		s.Proxy = &ProxySettings{Enabled: enabled == "true", URL: secret.RedactURL(proxyURL)}
+	}
+
+	var cfg model.TelegramConfig
+	repository.GetDB().Where("user_id = ?", c.user.ID).First(&cfg)
+	if cfg.Targets == nil {
+		cfg.Targets = []model.TelegramTarget{}
+	}
+	if cfg.BotUsers == nil {
+		cfg.BotUsers = []int64{}
+	}
+	s.Telegram = TelegramSettings{
+		Enabled:        cfg.Enabled,
+		BotToken:       secret.Redact(string(cfg.BotToken)),
+		Targets:        cfg.Targets,
+		BotUsers:       cfg.BotUsers,
+		SendIcon:       cfg.SendIcon,
+		DigestSchedule: cfg.DigestSchedule,
+		DigestTimezone: cfg.DigestTimezone,
+	}
+	return s, nil
+}
+
+func (c *localClient) SaveProxy(p *ProxySettings) error {
+	if !c.user.IsAdmin() {
+		return errPermission
+	}
+	existing, err := repository.GetSetting("proxy_url")
+	if err != nil {
+		return err
+	}
+	if err := repository.SetSetting("proxy_enabled", strconv.FormatBool(p.Enabled)); err != nil {
+		return err
+	}
+	return repository.SetSetting("proxy_url", secret.RestoreURL(p.URL, existing))
+}
+
+func (c *localClient) SaveTelegram(t *TelegramSettings) error {
+	if !c.user.IsAdmin() {
+		return errPermission
+	}
+
+	var cfg model.TelegramConfig
+	repository.GetDB().FirstOrCreate(&cfg, model.TelegramConfig{UserID: c.user.ID})
+	// A redacted token means keep the stored one
+	if t.BotToken != "" && !secret.IsRedacted(t.BotToken) {
+		cfg.BotToken = model.EncryptedString(t.BotToken)
+	}
+	cfg.Targets = t.Targets
+	cfg.BotUsers = t.BotUsers
+	cfg.SendIcon = t.SendIcon
+	cfg.Enabled = t.Enabled
+	cfg.DigestSchedule = strings.TrimSpace(t.DigestSchedule)
+	cfg.DigestTimezone = strings.TrimSpace(t.DigestTimezone)
+	if err := cfg.ValidateDigest(); err != nil {
+		return err
+	}
+	return repository.GetDB().Save(&cfg).Error
+}
+
+func toMonitor(m *model.Monitor) Monitor {
+	return Monitor{
+		ID:            m.ID,
+		AppID:         m.AppID,
+		AppName:       m.AppName,
+		TestFlightURL: m.TestFlightURL,
+		Status:        string(m.Status),
+		Interval:      m.Interval,
+		Duration:      m.Duration,
+		NotifyMode:    string(m.NotifyMode),
+		Enabled:       m.Enabled,
+		LastCheck:     m.LastCheck,
+		LastError:     m.LastError,
+		ExpireAt:      m.ExpireAt,
+	}
+}
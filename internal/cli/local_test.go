@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tf-monitor/internal/model"
+	"tf-monitor/internal/repository"
+)
+
+// fakeTestFlight answers every request with a page of a beta that has open
+// slots
+type fakeTestFlight struct{}
+
+func (fakeTestFlight) RoundTrip(r *http.Request) (*http.Response, error) {
+	page := `<html><head><meta property="og:title" content="Join the App A beta"></head>` +
+		`<body><a href="#">Start Testing</a></body></html>`
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/html"}},
+		Body:       io.NopCloser(strings.NewReader(page)),
+		Request:    r,
+	}, nil
+}
+
+func TestLocalCheckQueuesAlert(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tf-monitor.db")
+	if err := repository.InitDB(path); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() {
+		if db, err := repository.GetDB().DB(); err == nil {
+			db.Close()
+		}
+	})
+	db := repository.GetDB()
+	admin := &model.User{Username: "admin", Role: model.RoleAdmin}
+	if err := db.Create(admin).Error; err != nil {
+		t.Fatal(err)
+	}
+	// The monitor belongs to another user, its alert goes to their chats
+	owner := &model.User{Username: "alice", Role: model.RoleEditor}
+	if err := db.Create(owner).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&model.TelegramConfig{
+		UserID:   owner.ID,
+		Enabled:  true,
+		BotToken: "123456:token",
+		Targets:  []model.TelegramTarget{{ChatID: "1"}},
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	m := &model.Monitor{
+		UserID: owner.ID, AppID: "aaaa1111", TestFlightURL: "https://testflight.apple.com/join/aaaa1111",
+		Interval: 60, NotifyMode: model.NotifyOnce, Status: model.StatusFull, Enabled: true,
+	}
+	if err := db.Create(m).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	transport := http.DefaultTransport
+	http.DefaultTransport = fakeTestFlight{}
+	t.Cleanup(func() { http.DefaultTransport = transport })
+	t.Setenv("DB_PATH", path)
+	t.Setenv("ADMIN_USERNAME", "admin")
+	t.Setenv("TF_MONITOR_SERVER", "")
+
+	if code := Run([]string{"check", "-o", "json", "1"}); code != exitOK {
+		t.Fatalf("check exited with %d", code)
+	}
+
+	var checked model.Monitor
+	if err := db.First(&checked, m.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if checked.Status != model.StatusAvailable || !checked.Notified {
+		t.Errorf("monitor status = %q, notified = %v", checked.Status, checked.Notified)
+	}
+	var queued []model.Notification
+	if err := db.Where("monitor_id = ?", m.ID).Find(&queued).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(queued) != 1 || queued[0].UserID != owner.ID {
+		t.Errorf("queued notifications = %+v, want one for the owner", queued)
+	}
+}
@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"tf-monitor/internal/config"
+	"tf-monitor/internal/model"
+	"tf-monitor/internal/service/monitor"
+)
+
+// Conditions of -fail-if
+const (
+	failIfNone = "none" // no link has an open slot
+	failIfAny  = "any"  // some link has an open slot
+)
+
+// LinkResult is the result of checking a link once
+type LinkResult struct {
+	URL     string `json:"url"`
+	AppID   string `json:"appId"`
+	AppName string `json:"appName"`
+	Status  string `json:"status"` // available, full or error
+	Message string `json:"message"`
+	Error   string `json:"error,omitempty"`
+}
+
+// isMonitorIDs reports whether args name monitors rather than links, check
+// works on monitors only when all of them are IDs
+func isMonitorIDs(args []string) bool {
+	for _, arg := range args {
+		if _, err := strconv.ParseUint(strings.TrimPrefix(arg, "#"), 10, 32); err != nil {
+			return false
+		}
+	}
+	return len(args) > 0
+}
+
+// probe checks each link once, without database or server. The exit code is
+// 1 when the failIf condition holds, links that cannot be checked count as
+// unavailable.
+func probe(out *printer, links []string, failIf string) int {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return exitError
+	}
+	proxyURL := ""
+	if cfg.Proxy.Enabled {
+		proxyURL = cfg.Proxy.URL
+	}
+	checker := monitor.NewChecker(proxyURL)
+
+	results := make([]LinkResult, len(links))
+	available := 0
+	for i, link := range links {
+		r := LinkResult{URL: link, Status: string(model.StatusError)}
+		appID, err := monitor.ParseURL(link)
+		if err == nil {
+			r.AppID = appID
+			var info *monitor.TestFlightInfo
+			if info, err = checker.Check(appID); err == nil {
+				r.AppName = info.AppName
+				r.Message = info.Message
+				r.Status = string(model.StatusFull)
+				if info.Available {
+					r.Status = string(model.StatusAvailable)
+					available++
+				}
+			}
+		}
+		if err != nil {
+			r.Error = err.Error()
+		}
+		results[i] = r
+	}
+
+	if err := out.links(results); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return exitError
+	}
+	if (failIf == failIfNone && available == 0) || (failIf == failIfAny && available > 0) {
+		return exitError
+	}
+	return exitOK
+}
+
+func (p *printer) links(results []LinkResult) error {
+	if p.json {
+		return p.writeJSON(results)
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "URL\tAPP\tSTATUS\tMESSAGE")
+	for _, r := range results {
+		name := r.AppName
+		if name == "" {
+			name = r.AppID
+		}
+		message := r.Message
+		if r.Error != "" {
+			message = r.Error
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.URL, name, r.Status, message)
+	}
+	return tw.Flush()
+}
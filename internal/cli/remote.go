@@ -0,0 +1,179 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// remoteClient works on a running server through its HTTP API
+type remoteClient struct {
+	baseURL string // up to and including /api
+	token   string
+	http    *http.Client
+}
+
+// apiError is an error response of the API
+type apiError struct {
+	status  int
+	message string
+}
+
+func (e *apiError) Error() string {
+	if e.status == http.StatusUnauthorized {
+		return "not authorized, check the token"
+	}
+	if e.message == "" {
+		return http.StatusText(e.status)
+	}
+	return e.message
+}
+
+func newRemoteClient(server, token string) *remoteClient {
+	return &remoteClient{
+		baseURL: strings.TrimSuffix(server, "/") + "/api",
+		token:   token,
+		// Checks wait for the answer of TestFlight
+		http: &http.Client{Timeout: time.Minute},
+	}
+}
+
+// do sends a request with body as JSON and decodes the response into out
+func (c *remoteClient) do(method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&errResp)
+		return &apiError{status: resp.StatusCode, message: errResp.Error}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("unexpected response from %s: %w", req.URL, err)
+	}
+	return nil
+}
+
+// monitor sends a request that responds with a monitor
+func (c *remoteClient) monitor(method, path string, body any) (*Monitor, error) {
+	var resp struct {
+		Data Monitor `json:"data"`
+	}
+	if err := c.do(method, path, body, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Data, nil
+}
+
+func (c *remoteClient) List(all bool) ([]Monitor, error) {
+	var resp struct {
+		Data []Monitor `json:"data"`
+	}
+	path := "/monitors"
+	if all {
+		path += "?all=true"
+	}
+	if err := c.do(http.MethodGet, path, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+func (c *remoteClient) Add(req AddRequest) ([]Monitor, []string, error) {
+	var resp struct {
+		Created []Monitor `json:"created"`
+		Errors  []string  `json:"errors"`
+	}
+	err := c.do(http.MethodPost, "/monitors", map[string]any{
+		"urls":       strings.Join(req.URLs, "\n"),
+		"interval":   req.Interval,
+		"duration":   req.Duration,
+		"notifyMode": req.NotifyMode,
+		"autoStart":  !req.Paused,
+	}, &resp)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp.Created, resp.Errors, nil
+}
+
+func (c *remoteClient) SetEnabled(id uint, enabled bool) (*Monitor, error) {
+	return c.monitor(http.MethodPost, fmt.Sprintf("/monitors/%d/toggle", id), map[string]bool{"enabled": enabled})
+}
+
+func (c *remoteClient) Delete(id uint) (*Monitor, error) {
+	// Fetched first, the deletion responds without the monitor
+	m, err := c.monitor(http.MethodGet, fmt.Sprintf("/monitors/%d", id), nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.do(http.MethodDelete, fmt.Sprintf("/monitors/%d", id), nil, nil); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *remoteClient) Check(id uint) (*Monitor, error) {
+	return c.monitor(http.MethodPost, fmt.Sprintf("/monitors/%d/check", id), nil)
+}
+
+func (c *remoteClient) Settings() (*Settings, error) {
+	s := &Settings{}
+
+	var proxy ProxySettings
+	err := c.do(http.MethodGet, "/proxy", nil, &proxy)
+	var apiErr *apiError
+	switch {
+	case err == nil:
+		s.Proxy = &proxy
+	case errors.As(err, &apiErr) && apiErr.status == http.StatusForbidden:
+		// Proxy settings are for admins only
+	default:
+		return nil, err
+	}
+
+	// The response has the same fields as TelegramSettings
+	if err := c.do(http.MethodGet, "/telegram", nil, &s.Telegram); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (c *remoteClient) SaveProxy(p *ProxySettings) error {
+	return c.do(http.MethodPut, "/proxy", p, nil)
+}
+
+func (c *remoteClient) SaveTelegram(t *TelegramSettings) error {
+	return c.do(http.MethodPut, "/telegram", t, nil)
+}
@@ -0,0 +1,216 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"tf-monitor/internal/model"
+)
+
+// Settings are the proxy settings of the server and the Telegram settings of
+// the user. Secrets are redacted, saving them back unchanged keeps them.
+type Settings struct {
+	Proxy    *ProxySettings   `json:"proxy,omitempty"` // nil for users other than admins
+	Telegram TelegramSettings `json:"telegram"`
+}
+
+type ProxySettings struct {
+	Enabled bool   `json:"enabled"`
+	URL     string `json:"url"`
+}
+
+type TelegramSettings struct {
+	Enabled        bool                   `json:"enabled"`
+	BotToken       string                 `json:"botToken"`
+	Targets        []model.TelegramTarget `json:"targets"`
+	BotUsers       []int64                `json:"botUsers"`
+	SendIcon       bool                   `json:"sendIcon"`
+	DigestSchedule string                 `json:"digestSchedule"`
+	DigestTimezone string                 `json:"digestTimezone"`
+}
+
+// settingKeys lists the keys of the settings command in the order they are
+// printed
+var settingKeys = []string{
+	"proxy.enabled",
+	"proxy.url",
+	"telegram.enabled",
+	"telegram.botToken",
+	"telegram.chats",
+	"telegram.botUsers",
+	"telegram.sendIcon",
+	"telegram.digestSchedule",
+	"telegram.digestTimezone",
+}
+
+// settings prints the settings, or changes those named in assignments of the
+// form key=value first
+func settings(c client, out *printer, assignments []string) error {
+	s, err := c.Settings()
+	if err != nil {
+		return err
+	}
+
+	proxyChanged, telegramChanged := false, false
+	for _, assignment := range assignments {
+		key, value, ok := strings.Cut(assignment, "=")
+		if !ok {
+			return fmt.Errorf("expected key=value, got %q", assignment)
+		}
+		if err := s.set(key, value); err != nil {
+			return err
+		}
+		if strings.HasPrefix(key, "proxy.") {
+			proxyChanged = true
+		} else {
+			telegramChanged = true
+		}
+	}
+
+	if proxyChanged {
+		if err := c.SaveProxy(s.Proxy); err != nil {
+			return err
+		}
+	}
+	if telegramChanged {
+		if err := c.SaveTelegram(&s.Telegram); err != nil {
+			return err
+		}
+	}
+	if proxyChanged || telegramChanged {
+		// Read back in the form the server stored
+		if s, err = c.Settings(); err != nil {
+			return err
+		}
+	}
+	return out.settings(s)
+}
+
+// set changes the setting named key
+func (s *Settings) set(key, value string) error {
+	if strings.HasPrefix(key, "proxy.") && s.Proxy == nil {
+		return fmt.Errorf("only admins may change the proxy settings")
+	}
+
+	var err error
+	t := &s.Telegram
+	switch key {
+	case "proxy.enabled":
+		s.Proxy.Enabled, err = strconv.ParseBool(value)
+	case "proxy.url":
+		s.Proxy.URL = value
+	case "telegram.enabled":
+		t.Enabled, err = strconv.ParseBool(value)
+	case "telegram.botToken":
+		t.BotToken = value
+	case "telegram.chats":
+		t.Targets = setChats(t.Targets, value)
+	case "telegram.botUsers":
+		users, err := parseUserIDs(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		t.BotUsers = users
+	case "telegram.sendIcon":
+		t.SendIcon, err = strconv.ParseBool(value)
+	case "telegram.digestSchedule":
+		t.DigestSchedule = value
+	case "telegram.digestTimezone":
+		t.DigestTimezone = value
+	default:
+		return fmt.Errorf("unknown setting %q, known are %s", key, strings.Join(settingKeys, ", "))
+	}
+	if err != nil {
+		return fmt.Errorf("%s must be true or false", key)
+	}
+	return nil
+}
+
+// get returns the setting named key as printed
+func (s *Settings) get(key string) string {
+	t := &s.Telegram
+	switch key {
+	case "proxy.enabled":
+		return strconv.FormatBool(s.Proxy.Enabled)
+	case "proxy.url":
+		return s.Proxy.URL
+	case "telegram.enabled":
+		return strconv.FormatBool(t.Enabled)
+	case "telegram.botToken":
+		return t.BotToken
+	case "telegram.chats":
+		chats := make([]string, len(t.Targets))
+		for i, target := range t.Targets {
+			chats[i] = target.ChatID
+		}
+		return strings.Join(chats, ",")
+	case "telegram.botUsers":
+		users := make([]string, len(t.BotUsers))
+		for i, id := range t.BotUsers {
+			users[i] = strconv.FormatInt(id, 10)
+		}
+		return strings.Join(users, ",")
+	case "telegram.sendIcon":
+		return strconv.FormatBool(t.SendIcon)
+	case "telegram.digestSchedule":
+		return t.DigestSchedule
+	case "telegram.digestTimezone":
+		return t.DigestTimezone
+	}
+	return ""
+}
+
+// setChats replaces the chats of targets with the comma separated chat IDs.
+// Chats that remain keep their options, new ones get the default route.
+func setChats(targets []model.TelegramTarget, value string) []model.TelegramTarget {
+	result := []model.TelegramTarget{}
+	for _, chatID := range strings.Split(value, ",") {
+		chatID = strings.TrimSpace(chatID)
+		if chatID == "" {
+			continue
+		}
+		target := model.TelegramTarget{ChatID: chatID}
+		for _, existing := range targets {
+			if existing.ChatID == chatID {
+				target = existing
+				break
+			}
+		}
+		result = append(result, target)
+	}
+	return result
+}
+
+// parseUserIDs parses comma separated Telegram user IDs
+func parseUserIDs(value string) ([]int64, error) {
+	ids := []int64{}
+	for _, field := range strings.Split(value, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Telegram user id %q", field)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (p *printer) settings(s *Settings) error {
+	if p.json {
+		return p.writeJSON(s)
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tVALUE")
+	for _, key := range settingKeys {
+		if strings.HasPrefix(key, "proxy.") && s.Proxy == nil {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", key, s.get(key))
+	}
+	return tw.Flush()
+}
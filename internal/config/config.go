@@ -1,18 +1,33 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
 )
 
 type Config struct {
 	Server   ServerConfig
 	Database DatabaseConfig
 	Proxy    ProxyConfig
+	Auth     AuthConfig
+	OIDC     OIDCConfig
+	Header   TrustedHeaderConfig
+	Secret   SecretConfig
+	Bot      BotConfig
+	Notify   NotifyConfig
+	Webhook  WebhookConfig
+	Metrics  MetricsConfig
+	Log      LogConfig
+	Health   HealthConfig
 }
 
 type ServerConfig struct {
-	Port string
+	Port      string
+	BasePath  string // prefix of every route, e.g. "/tf", empty at the root
+	PublicURL string // where users reach the server including BasePath, used for links in notifications
+	WebDir    string // serves the frontend from disk instead of the embedded build
 }
 
 type DatabaseConfig struct {
@@ -24,10 +39,100 @@ type ProxyConfig struct {
 	URL     string
 }
 
-func Load() *Config {
+type AuthConfig struct {
+	AdminUsername   string
+	AdminPassword   string
+	SessionTTLHours int
+}
+
+type OIDCConfig struct {
+	Enabled       bool
+	Issuer        string
+	ClientID      string
+	ClientSecret  string
+	RedirectURL   string
+	Scopes        []string
+	UsernameClaim string
+	RoleClaim     string
+	RoleMapping   map[string]string // claim value -> role
+	DefaultRole   string
+}
+
+// TrustedHeaderConfig configures authentication by headers set by a reverse proxy
+type TrustedHeaderConfig struct {
+	Enabled      bool
+	UserHeader   string
+	GroupsHeader string
+	TrustedCIDRs []string
+	RoleMapping  map[string]string // group -> role
+	DefaultRole  string
+}
+
+// SecretConfig holds the master keys used to encrypt secrets at rest
+type SecretConfig struct {
+	Key          string
+	PreviousKeys []string // still accepted for decryption during key rotation
+}
+
+// BotConfig configures the interactive Telegram bot
+type BotConfig struct {
+	Token         string
+	Mode          string // "polling" or "webhook"
+	WebhookURL    string
+	WebhookSecret string
+}
+
+// NotifyConfig is the global notification policy, monitors may override it
+type NotifyConfig struct {
+	MinRepeatInterval int    // seconds between two alerts of a monitor
+	MaxPerHour        int    // alerts per monitor and hour
+	QuietHours        string // "22:00-07:00"
+	QuietMode         string // "queue" or "drop"
+	Timezone          string
+	DedupWindow       int // seconds a chat alerted of an app is skipped by alerts of other monitors of it
+}
+
+// WebhookConfig restricts where webhooks are delivered
+type WebhookConfig struct {
+	AllowPrivate bool // allow loopback, private and link-local addresses
+}
+
+// MetricsConfig protects the Prometheus endpoint
+type MetricsConfig struct {
+	Token string // bearer token required to scrape, open when empty
+}
+
+// LogConfig selects the log level and output format
+type LogConfig struct {
+	Level  string // debug, info, warn or error
+	Format string // "text" or "json"
+}
+
+// HealthConfig sets when the readiness check fails
+type HealthConfig struct {
+	CheckThreshold int // seconds without a successful check
+}
+
+// Load reads the configuration from the environment. It fails when a secret
+// is to be read from a file that cannot be read, rather than running without
+// it.
+func Load() (*Config, error) {
+	secretKey, err := getEnvOrFile("SECRET_KEY", "SECRET_KEY_FILE")
+	if err != nil {
+		return nil, err
+	}
+	metricsToken, err := getEnvOrFile("METRICS_TOKEN", "METRICS_TOKEN_FILE")
+	if err != nil {
+		return nil, err
+	}
+
+	basePath := normalizeBasePath(getEnv("BASE_PATH", ""))
 	return &Config{
 		Server: ServerConfig{
-			Port: getEnv("SERVER_PORT", "8080"),
+			Port:      getEnv("SERVER_PORT", "8080"),
+			BasePath:  basePath,
+			PublicURL: publicURL(getEnv("PUBLIC_URL", ""), basePath),
+			WebDir:    getEnv("WEB_DIR", ""),
 		},
 		Database: DatabaseConfig{
 			Path: getEnv("DB_PATH", "data/tf-monitor.db"),
@@ -36,7 +141,83 @@ func Load() *Config {
 			Enabled: getEnvBool("PROXY_ENABLED", false),
 			URL:     getEnv("PROXY_URL", ""),
 		},
+		Auth: AuthConfig{
+			AdminUsername:   getEnv("ADMIN_USERNAME", "admin"),
+			AdminPassword:   getEnv("ADMIN_PASSWORD", ""),
+			SessionTTLHours: getEnvInt("SESSION_TTL_HOURS", 168),
+		},
+		OIDC: OIDCConfig{
+			Enabled:       getEnvBool("OIDC_ENABLED", false),
+			Issuer:        getEnv("OIDC_ISSUER", ""),
+			ClientID:      getEnv("OIDC_CLIENT_ID", ""),
+			ClientSecret:  getEnv("OIDC_CLIENT_SECRET", ""),
+			RedirectURL:   getEnv("OIDC_REDIRECT_URL", ""),
+			Scopes:        strings.Fields(getEnv("OIDC_SCOPES", "openid profile email")),
+			UsernameClaim: getEnv("OIDC_USERNAME_CLAIM", "preferred_username"),
+			RoleClaim:     getEnv("OIDC_ROLE_CLAIM", "groups"),
+			RoleMapping:   getEnvMap("OIDC_ROLE_MAPPING"),
+			DefaultRole:   getEnv("OIDC_DEFAULT_ROLE", "viewer"),
+		},
+		Header: TrustedHeaderConfig{
+			Enabled:      getEnvBool("TRUSTED_HEADER_ENABLED", false),
+			UserHeader:   getEnv("TRUSTED_HEADER_USER", "Remote-User"),
+			GroupsHeader: getEnv("TRUSTED_HEADER_GROUPS", "Remote-Groups"),
+			TrustedCIDRs: getEnvList("TRUSTED_HEADER_CIDRS"),
+			RoleMapping:  getEnvMap("TRUSTED_HEADER_ROLE_MAPPING"),
+			DefaultRole:  getEnv("TRUSTED_HEADER_DEFAULT_ROLE", "viewer"),
+		},
+		Secret: SecretConfig{
+			Key:          secretKey,
+			PreviousKeys: getEnvList("SECRET_KEY_PREVIOUS"),
+		},
+		Bot: BotConfig{
+			Token:         getEnv("BOT_TOKEN", ""),
+			Mode:          getEnv("BOT_MODE", "polling"),
+			WebhookURL:    getEnv("BOT_WEBHOOK_URL", ""),
+			WebhookSecret: getEnv("BOT_WEBHOOK_SECRET", ""),
+		},
+		Notify: NotifyConfig{
+			MinRepeatInterval: getEnvInt("NOTIFY_MIN_REPEAT_INTERVAL", 0),
+			MaxPerHour:        getEnvInt("NOTIFY_MAX_PER_HOUR", 0),
+			QuietHours:        getEnv("NOTIFY_QUIET_HOURS", ""),
+			QuietMode:         getEnv("NOTIFY_QUIET_MODE", "queue"),
+			Timezone:          getEnv("NOTIFY_TIMEZONE", ""),
+			DedupWindow:       getEnvInt("NOTIFY_DEDUP_WINDOW", 300),
+		},
+		Webhook: WebhookConfig{
+			AllowPrivate: getEnvBool("WEBHOOK_ALLOW_PRIVATE", false),
+		},
+		Metrics: MetricsConfig{
+			Token: metricsToken,
+		},
+		Log: LogConfig{
+			Level:  getEnv("LOG_LEVEL", "info"),
+			Format: getEnv("LOG_FORMAT", "text"),
+		},
+		Health: HealthConfig{
+			CheckThreshold: getEnvInt("READY_CHECK_THRESHOLD", 900),
+		},
+	}, nil
+}
+
+// normalizeBasePath turns "tf", "/tf/" and the like into "/tf", and the root
+// into ""
+func normalizeBasePath(path string) string {
+	path = strings.Trim(strings.TrimSpace(path), "/")
+	if path == "" {
+		return ""
 	}
+	return "/" + path
+}
+
+// publicURL trims the trailing slash of url and appends basePath unless it is
+// already included
+func publicURL(url, basePath string) string {
+	url = strings.TrimSuffix(url, "/")
+	if url == "" || strings.HasSuffix(url, basePath) {
+		return url
+	}
+	return url + basePath
 }
 
 func getEnv(key, defaultValue string) string {
@@ -56,3 +237,59 @@ func getEnvBool(key string, defaultValue bool) bool {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return defaultValue
+		}
+		return i
+	}
+	return defaultValue
+}
+
+// getEnvOrFile reads a value from an env var, or from the file named by
+// fileKey. A file that is named but cannot be read, or is empty, is an error.
+func getEnvOrFile(key, fileKey string) (string, error) {
+	if value := os.Getenv(key); value != "" {
+		return value, nil
+	}
+	path := os.Getenv(fileKey)
+	if path == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", fileKey, err)
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "" {
+		return "", fmt.Errorf("%s: %s is empty", fileKey, path)
+	}
+	return value, nil
+}
+
+// getEnvList parses a comma separated list
+func getEnvList(key string) []string {
+	var result []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// getEnvMap parses a comma separated list of key=value pairs
+func getEnvMap(key string) map[string]string {
+	result := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv(key), ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		result[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return result
+}
@@ -0,0 +1,127 @@
+// Package logging configures structured logging with log/slog and provides
+// the attributes shared across the services, so log lines can be filtered by
+// monitor, app or channel
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"tf-monitor/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Setup installs the default logger. Output of the standard log package is
+// routed through it as well.
+func Setup(cfg config.LogConfig, w io.Writer) error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return fmt.Errorf("invalid log level %q", cfg.Level)
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	case "text", "":
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		return fmt.Errorf("invalid log format %q", cfg.Format)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// MonitorID is the attribute naming a monitor
+func MonitorID(id uint) slog.Attr {
+	return slog.Uint64("monitor_id", uint64(id))
+}
+
+// AppID is the attribute naming a TestFlight app
+func AppID(id string) slog.Attr {
+	return slog.String("app_id", id)
+}
+
+// Channel is the attribute naming a notification channel
+func Channel(channel string) slog.Attr {
+	return slog.String("channel", channel)
+}
+
+// Duration is the attribute for how long something took, in seconds
+func Duration(d time.Duration) slog.Attr {
+	return slog.Float64("duration", d.Seconds())
+}
+
+// Err is the attribute for an error
+func Err(err error) slog.Attr {
+	return slog.Any("error", err)
+}
+
+const contextLoggerKey = "logger"
+
+// Middleware logs every request and gives handlers a logger carrying the
+// request ID, see FromContext. Successful requests to quietPaths, such as
+// probes and scrapes, are logged at debug level.
+func Middleware(quietPaths ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		requestID := c.GetHeader("X-Request-ID")
+		if requestID == "" {
+			requestID = newRequestID()
+		}
+		c.Header("X-Request-ID", requestID)
+		c.Set(contextLoggerKey, slog.Default().With(slog.String("request_id", requestID)))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		case slices.Contains(quietPaths, c.Request.URL.Path):
+			level = slog.LevelDebug
+		}
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			Duration(time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", strings.Join(c.Errors.Errors(), "; ")))
+		}
+		FromContext(c).LogAttrs(c.Request.Context(), level, "HTTP request", attrs...)
+	}
+}
+
+// FromContext returns the logger of a request
+func FromContext(c *gin.Context) *slog.Logger {
+	if logger, ok := c.Get(contextLoggerKey); ok {
+		return logger.(*slog.Logger)
+	}
+	return slog.Default()
+}
+
+// With adds attributes to the logger of a request
+func With(c *gin.Context, args ...any) {
+	c.Set(contextLoggerKey, FromContext(c).With(args...))
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"strconv"
+
+	"tf-monitor/internal/model"
+	"tf-monitor/internal/service/events"
+)
+
+// Metrics recorded by the monitor, notification and TestFlight services
+var (
+	Checks = NewCounterVec("tfmonitor_checks_total",
+		"TestFlight checks by monitor and result (available, full, error).",
+		"monitor_id", "app_id", "result")
+
+	CheckDuration = NewHistogramVec("tfmonitor_check_duration_seconds",
+		"Duration of TestFlight checks.",
+		[]float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+		"result")
+
+	AppleResponses = NewCounterVec("tfmonitor_apple_responses_total",
+		"HTTP responses from TestFlight by status code, \"error\" when no response was received.",
+		"code")
+
+	Notifications = NewCounterVec("tfmonitor_notifications_total",
+		"Notification delivery attempts by channel and result (sent, retry, failed).",
+		"channel", "result")
+
+	WebhookDeliveries = NewCounterVec("tfmonitor_webhook_deliveries_total",
+		"Webhook delivery attempts by result (sent, retry, failed).",
+		"result")
+)
+
+// ForgetMonitor drops the series of a deleted monitor
+func ForgetMonitor(m *model.Monitor) {
+	Checks.Delete(strconv.FormatUint(uint64(m.ID), 10), m.AppID)
+}
+
+// Listen records the checks and notification deliveries published on bus and
+// forgets deleted monitors
+func Listen(bus *events.Bus) {
+	bus.CheckCompleted.Subscribe(func(e events.CheckCompleted) error {
+		result := model.StatusFull
+		switch {
+		case e.Err != nil:
+			result = model.StatusError
+		case e.Available:
+			result = model.StatusAvailable
+		}
+		Checks.Inc(strconv.FormatUint(uint64(e.Monitor.ID), 10), e.Monitor.AppID, string(result))
+		CheckDuration.Observe(e.Duration.Seconds(), string(result))
+		return nil
+	})
+	bus.MonitorDeleted.Subscribe(func(e events.MonitorDeleted) error {
+		m := *e.Monitor
+		e.Tx.AfterCommit(func() { ForgetMonitor(&m) })
+		return nil
+	})
+	bus.NotificationSent.Subscribe(func(e events.NotificationSent) error {
+		result := "retry"
+		switch {
+		case e.Err == nil:
+			result = "sent"
+		case e.Notification.Status == model.NotificationFailed:
+			result = "failed"
+		}
+		Notifications.Inc(e.Notification.Channel, result)
+		return nil
+	})
+}
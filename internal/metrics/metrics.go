@@ -0,0 +1,264 @@
+// Package metrics keeps counters, histograms and gauges and serves them in the
+// Prometheus text exposition format
+package metrics
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+type metric interface {
+	write(w io.Writer)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []metric
+)
+
+func register(m metric) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, m)
+}
+
+// Write renders every metric in the text exposition format
+func Write(w io.Writer) {
+	registryMu.Lock()
+	metrics := append([]metric(nil), registry...)
+	registryMu.Unlock()
+
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// Handler serves the metrics, requiring token as a bearer token when set
+func Handler(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token != "" {
+			given := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				c.AbortWithStatus(http.StatusUnauthorized)
+				return
+			}
+		}
+		c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		c.Status(http.StatusOK)
+		Write(c.Writer)
+	}
+}
+
+// series holds the values of a metric per label combination
+type series[T any] struct {
+	mu     sync.Mutex
+	labels []string
+	values map[string]*T
+	keys   map[string][]string // label values by key
+}
+
+func newSeries[T any](labels []string) series[T] {
+	return series[T]{labels: labels, values: map[string]*T{}, keys: map[string][]string{}}
+}
+
+// get returns the value for the label values, the caller holds s.mu
+func (s *series[T]) get(values []string) *T {
+	if len(values) != len(s.labels) {
+		panic(fmt.Sprintf("metrics: got %d label values for %d labels", len(values), len(s.labels)))
+	}
+	key := strings.Join(values, "\xff")
+	v, ok := s.values[key]
+	if !ok {
+		v = new(T)
+		s.values[key] = v
+		s.keys[key] = append([]string(nil), values...)
+	}
+	return v
+}
+
+// delete removes the series whose leading label values are values, the
+// caller holds s.mu
+func (s *series[T]) delete(values []string) {
+	if len(values) > len(s.labels) {
+		panic(fmt.Sprintf("metrics: got %d label values for %d labels", len(values), len(s.labels)))
+	}
+	for key, keyValues := range s.keys {
+		if slices.Equal(keyValues[:len(values)], values) {
+			delete(s.values, key)
+			delete(s.keys, key)
+		}
+	}
+}
+
+// sorted returns the keys in a stable order, the caller holds s.mu
+func (s *series[T]) sorted() []string {
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// CounterVec is a counter partitioned by labels
+type CounterVec struct {
+	name, help string
+	series[float64]
+}
+
+// NewCounterVec registers a counter
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{name: name, help: help, series: newSeries[float64](labels)}
+	register(c)
+	return c
+}
+
+// Inc adds one to the counter of the label values
+func (c *CounterVec) Inc(values ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	*c.get(values)++
+}
+
+// Delete removes the counters of the label values. Trailing labels may be
+// left out to remove every counter matching the given ones.
+func (c *CounterVec) Delete(values ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.delete(values)
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	writeHeader(w, c.name, c.help, "counter")
+	for _, key := range c.sorted() {
+		writeSample(w, c.name, c.labels, c.keys[key], *c.values[key])
+	}
+}
+
+// HistogramVec counts observations in buckets, partitioned by labels
+type HistogramVec struct {
+	name, help string
+	buckets    []float64
+	series[histogram]
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec registers a histogram with the given upper bucket bounds
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{name: name, help: help, buckets: buckets, series: newSeries[histogram](labels)}
+	register(h)
+	return h
+}
+
+// Observe records v for the label values
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	hist := h.get(values)
+	if hist.counts == nil {
+		hist.counts = make([]uint64, len(h.buckets))
+	}
+	for i, bound := range h.buckets {
+		if v <= bound {
+			hist.counts[i]++
+			break
+		}
+	}
+	hist.count++
+	hist.sum += v
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	writeHeader(w, h.name, h.help, "histogram")
+	labels := append(append([]string(nil), h.labels...), "le")
+	for _, key := range h.sorted() {
+		hist, values := h.values[key], h.keys[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += hist.counts[i]
+			writeSample(w, h.name+"_bucket", labels, append(values, formatFloat(bound)), float64(cumulative))
+		}
+		writeSample(w, h.name+"_bucket", labels, append(values, "+Inf"), float64(hist.count))
+		writeSample(w, h.name+"_sum", h.labels, values, hist.sum)
+		writeSample(w, h.name+"_count", h.labels, values, float64(hist.count))
+	}
+}
+
+// Sample is a gauge value with its label values
+type Sample struct {
+	Values []string
+	Value  float64
+}
+
+// GaugeFunc is a gauge whose samples are read when metrics are scraped
+type GaugeFunc struct {
+	name, help string
+	labels     []string
+	collect    func() []Sample
+}
+
+// NewGaugeFunc registers a gauge that reads its samples from collect
+func NewGaugeFunc(name, help string, collect func() []Sample, labels ...string) *GaugeFunc {
+	g := &GaugeFunc{name: name, help: help, labels: labels, collect: collect}
+	register(g)
+	return g
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	samples := g.collect()
+	sort.Slice(samples, func(i, j int) bool {
+		return strings.Join(samples[i].Values, "\xff") < strings.Join(samples[j].Values, "\xff")
+	})
+
+	writeHeader(w, g.name, g.help, "gauge")
+	for _, s := range samples {
+		writeSample(w, g.name, g.labels, s.Values, s.Value)
+	}
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func writeSample(w io.Writer, name string, labels, values []string, v float64) {
+	if len(labels) == 0 {
+		fmt.Fprintf(w, "%s %s\n", name, formatFloat(v))
+		return
+	}
+	pairs := make([]string, len(labels))
+	for i, label := range labels {
+		pairs[i] = label + `="` + labelEscaper.Replace(values[i]) + `"`
+	}
+	fmt.Fprintf(w, "%s{%s} %s\n", name, strings.Join(pairs, ","), formatFloat(v))
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
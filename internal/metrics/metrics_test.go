@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// render returns what m writes when scraped
+func render(m metric) string {
+	var b strings.Builder
+	m.write(&b)
+	return b.String()
+}
+
+func TestLabelEscaping(t *testing.T) {
+	tests := []struct {
+		value, want string
+	}{
+		{"plain", `plain`},
+		{`back\slash`, `back\\slash`},
+		{`"quoted"`, `\"quoted\"`},
+		{"two\nlines", `two\nlines`},
+		{"ünïcödé {}=,", "ünïcödé {}=,"},
+	}
+	for _, tt := range tests {
+		c := &CounterVec{name: "test_total", help: "Test.", series: newSeries[float64]([]string{"label"})}
+		c.Inc(tt.value)
+		want := `test_total{label="` + tt.want + `"} 1` + "\n"
+		if got := render(c); !strings.HasSuffix(got, want) {
+			t.Errorf("label %q rendered as\n%s\nwant suffix %s", tt.value, got, want)
+		}
+	}
+}
+
+func TestCounterVec(t *testing.T) {
+	c := &CounterVec{name: "checks_total", help: "Checks.", series: newSeries[float64]([]string{"monitor_id", "result"})}
+	c.Inc("2", "full")
+	c.Inc("1", "full")
+	c.Inc("1", "full")
+	c.Inc("1", "error")
+
+	want := `# HELP checks_total Checks.
+# TYPE checks_total counter
+checks_total{monitor_id="1",result="error"} 1
+checks_total{monitor_id="1",result="full"} 2
+checks_total{monitor_id="2",result="full"} 1
+`
+	if got := render(c); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	// Leaving out trailing labels removes every result of the monitor
+	c.Delete("1")
+	c.Delete("3")
+	want = `# HELP checks_total Checks.
+# TYPE checks_total counter
+checks_total{monitor_id="2",result="full"} 1
+`
+	if got := render(c); got != want {
+		t.Errorf("after Delete got\n%s\nwant\n%s", got, want)
+	}
+	c.Delete("2", "full")
+	if got := render(c); strings.Contains(got, "checks_total{") {
+		t.Errorf("after deleting every series got\n%s", got)
+	}
+}
+
+func TestHistogramVec(t *testing.T) {
+	h := &HistogramVec{name: "duration_seconds", help: "Durations.", buckets: []float64{0.5, 1, 2.5},
+		series: newSeries[histogram]([]string{"result"})}
+	for _, v := range []float64{0.1, 0.5, 0.7, 3, 60} {
+		h.Observe(v, "full")
+	}
+
+	want := `# HELP duration_seconds Durations.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{result="full",le="0.5"} 2
+duration_seconds_bucket{result="full",le="1"} 3
+duration_seconds_bucket{result="full",le="2.5"} 3
+duration_seconds_bucket{result="full",le="+Inf"} 5
+duration_seconds_sum{result="full"} 64.3
+duration_seconds_count{result="full"} 5
+`
+	if got := render(h); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestGaugeFunc(t *testing.T) {
+	g := &GaugeFunc{name: "active_jobs", help: "Jobs.", collect: func() []Sample {
+		return []Sample{{Value: 3}}
+	}}
+	want := "# HELP active_jobs Jobs.\n# TYPE active_jobs gauge\nactive_jobs 3\n"
+	if got := render(g); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestHandlerToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		token, header string
+		want          int
+	}{
+		{"", "", http.StatusOK},
+		{"secret", "Bearer secret", http.StatusOK},
+		{"secret", "Bearer other", http.StatusUnauthorized},
+		{"secret", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		r := gin.New()
+		r.GET("/metrics", Handler(tt.token))
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("token %q, header %q: status %d, want %d", tt.token, tt.header, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// ValidateDigest reports a malformed digest schedule or time zone. An empty
+// schedule disables digests.
+func (c *TelegramConfig) ValidateDigest() error {
+	if c.DigestSchedule != "" {
+		if d, err := time.ParseDuration(c.DigestSchedule); err == nil {
+			if d < time.Minute {
+				return fmt.Errorf("digest interval must be at least 1m")
+			}
+		} else if _, err := parseClock(c.DigestSchedule); err != nil {
+			return fmt.Errorf("invalid digest schedule %q, expected an interval like 15m or a time like 09:00", c.DigestSchedule)
+		}
+	}
+	if _, err := loadLocation(c.DigestTimezone); err != nil {
+		return fmt.Errorf("invalid timezone %q", c.DigestTimezone)
+	}
+	return nil
+}
+
+// DigestDue reports whether a digest covering the time since LastDigestAt is
+// due at now
+func (c *TelegramConfig) DigestDue(now time.Time) bool {
+	if c.DigestSchedule == "" || c.LastDigestAt == nil {
+		return false
+	}
+	if d, err := time.ParseDuration(c.DigestSchedule); err == nil {
+		return now.Sub(*c.LastDigestAt) >= d
+	}
+
+	minute, err := parseClock(c.DigestSchedule)
+	if err != nil {
+		return false
+	}
+	loc, err := loadLocation(c.DigestTimezone)
+	if err != nil {
+		return false
+	}
+
+	// Due once the latest daily send time has passed since the last digest
+	local := now.In(loc)
+	latest := time.Date(local.Year(), local.Month(), local.Day(), minute/60, minute%60, 0, 0, loc)
+	if latest.After(local) {
+		latest = latest.AddDate(0, 0, -1)
+	}
+	return c.LastDigestAt.Before(latest)
+}
+
+// DigestLocation returns the time zone digests are written in
+func (c *TelegramConfig) DigestLocation() *time.Location {
+	loc, err := loadLocation(c.DigestTimezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
@@ -0,0 +1,78 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDigestDue(t *testing.T) {
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 1, day, hour, minute, 0, 0, shanghai)
+	}
+
+	tests := []struct {
+		name     string
+		schedule string
+		last     time.Time // zero before the first digest
+		now      time.Time
+		want     bool
+	}{
+		{"disabled", "", at(1, 0, 0), at(2, 0, 0), false},
+		{"no start", "15m", time.Time{}, at(2, 0, 0), false},
+		{"invalid", "sometimes", at(1, 0, 0), at(2, 0, 0), false},
+
+		{"interval not reached", "15m", at(1, 9, 0), at(1, 9, 14), false},
+		{"interval reached", "15m", at(1, 9, 0), at(1, 9, 15), true},
+		{"interval overdue", "1h", at(1, 9, 0), at(1, 12, 0), true},
+
+		{"daily, before the time", "09:00", at(1, 9, 0), at(2, 8, 59), false},
+		{"daily, at the time", "09:00", at(1, 9, 0), at(2, 9, 0), true},
+		{"daily, sent today", "09:00", at(2, 9, 0), at(2, 18, 0), false},
+		{"daily, missed yesterday", "09:00", at(1, 8, 0), at(2, 8, 0), true},
+		{"daily, started after the time", "09:00", at(2, 10, 0), at(2, 23, 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := TelegramConfig{DigestSchedule: tt.schedule, DigestTimezone: "Asia/Shanghai"}
+			if !tt.last.IsZero() {
+				c.LastDigestAt = &tt.last
+			}
+			if got := c.DigestDue(tt.now); got != tt.want {
+				t.Errorf("DigestDue = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDigestDueTimezone(t *testing.T) {
+	// 09:00 in Shanghai is 01:00 UTC
+	last := time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC)
+	c := TelegramConfig{DigestSchedule: "09:00", DigestTimezone: "Asia/Shanghai", LastDigestAt: &last}
+	if c.DigestDue(time.Date(2024, 1, 2, 0, 59, 0, 0, time.UTC)) {
+		t.Error("due before 09:00 Shanghai time")
+	}
+	if !c.DigestDue(time.Date(2024, 1, 2, 1, 0, 0, 0, time.UTC)) {
+		t.Error("not due at 09:00 Shanghai time")
+	}
+}
+
+func TestValidateDigest(t *testing.T) {
+	tests := []struct {
+		schedule, timezone string
+		wantErr            bool
+	}{
+		{"", "", false},
+		{"15m", "", false},
+		{"09:00", "Asia/Shanghai", false},
+		{"30s", "", true},
+		{"9am", "", true},
+		{"09:00", "Nowhere/Special", true},
+	}
+	for _, tt := range tests {
+		c := TelegramConfig{DigestSchedule: tt.schedule, DigestTimezone: tt.timezone}
+		if err := c.ValidateDigest(); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateDigest(%q, %q) = %v", tt.schedule, tt.timezone, err)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package model
+
+import "gorm.io/gorm"
+
+// MonitorEvent records a status change of a monitor, digests summarise them
+type MonitorEvent struct {
+	gorm.Model
+	UserID        uint          `json:"userId" gorm:"index"`
+	MonitorID     uint          `json:"monitorId" gorm:"index"`
+	AppID         string        `json:"appId"`
+	AppName       string        `json:"appName"`
+	TestFlightURL string        `json:"testFlightUrl"`
+	From          MonitorStatus `json:"from"`
+	To            MonitorStatus `json:"to"`
+}
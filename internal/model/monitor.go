@@ -15,6 +15,7 @@ const (
 	StatusChecking  MonitorStatus = "checking"  // currently checking
 	StatusError     MonitorStatus = "error"     // check failed
 	StatusExpired   MonitorStatus = "expired"   // monitor duration expired
+	StatusJoined    MonitorStatus = "joined"    // user joined the beta, monitoring stopped
 )
 
 // NotifyMode represents how notifications should be sent
@@ -29,10 +30,11 @@ const (
 // Monitor represents a TestFlight app being monitored
 type Monitor struct {
 	gorm.Model
-	AppID         string        `json:"appId" gorm:"index"`          // TestFlight app ID
-	AppName       string        `json:"appName"`                     // App name (fetched from TestFlight)
-	IconURL       string        `json:"iconUrl"`                     // App icon URL
-	TestFlightURL string        `json:"testFlightUrl" gorm:"unique"` // Original TestFlight URL
+	UserID        uint          `json:"userId" gorm:"uniqueIndex:idx_monitor_user_url"`        // Owner of the monitor
+	AppID         string        `json:"appId" gorm:"index"`                                    // TestFlight app ID
+	AppName       string        `json:"appName"`                                               // App name (fetched from TestFlight)
+	IconURL       string        `json:"iconUrl"`                                               // App icon URL
+	TestFlightURL string        `json:"testFlightUrl" gorm:"uniqueIndex:idx_monitor_user_url"` // Original TestFlight URL
 	Status        MonitorStatus `json:"status" gorm:"default:checking"`
 	Interval      int           `json:"interval" gorm:"default:30"` // Check interval in seconds (min: 10)
 	Duration      int           `json:"duration" gorm:"default:24"` // Monitor duration in hours
@@ -42,17 +44,48 @@ type Monitor struct {
 	LastCheck     *time.Time    `json:"lastCheck"`                     // Last check timestamp
 	LastError     string        `json:"lastError"`                     // Last error message
 	ExpireAt      *time.Time    `json:"expireAt"`                      // When monitoring expires
+	SnoozedUntil  *time.Time    `json:"snoozedUntil"`                  // Notifications are muted until then
+	Policy        NotifyPolicy  `json:"policy" gorm:"embedded;embeddedPrefix:policy_"`
+	EscalateAfter int           `json:"escalateAfter"`                 // Minutes an alert may stay unacknowledged, 0 disables escalation
+	AlertedAt     *time.Time    `json:"alertedAt"`                     // First alert since the app became available
+	AckedAt       *time.Time    `json:"ackedAt"`                       // When that alert was acknowledged
+	Escalated     bool          `json:"escalated"`                     // Whether that alert was escalated
+	Routes        []string      `json:"routes" gorm:"serializer:json"` // Target groups receiving alerts, DefaultRoute when empty
 }
 
-// TelegramConfig stores Telegram notification settings
+// DisplayName returns the app name, or the app ID while the name is unknown
+func (m *Monitor) DisplayName() string {
+	if m.AppName != "" {
+		return m.AppName
+	}
+	return m.AppID
+}
+
+// TelegramConfig stores Telegram notification settings of a user
 type TelegramConfig struct {
 	gorm.Model
-	BotToken string `json:"botToken"`
-	ChatID   string `json:"chatId"`
-	Enabled  bool   `json:"enabled" gorm:"default:true"`
+	UserID         uint             `json:"userId" gorm:"uniqueIndex"`
+	BotToken       EncryptedString  `json:"botToken"`
+	Targets        []TelegramTarget `json:"targets" gorm:"serializer:json"`
+	BotUsers       []int64          `json:"botUsers" gorm:"serializer:json"` // Telegram users who may command the bot in group chats
+	SendIcon       bool             `json:"sendIcon"`                        // send alerts as a photo of the app icon
+	Enabled        bool             `json:"enabled" gorm:"default:true"`
+	DigestSchedule string           `json:"digestSchedule"` // "15m" for a fixed interval or "09:00" for daily
+	DigestTimezone string           `json:"digestTimezone"`
+	LastDigestAt   *time.Time       `json:"lastDigestAt"`
+}
+
+// TelegramTarget is a chat, or a topic of a forum group, that receives notifications
+type TelegramTarget struct {
+	ChatID     string `json:"chatId"`
+	ThreadID   int    `json:"threadId,omitempty"`
+	Group      string `json:"group,omitempty"` // route the target belongs to, DefaultRoute when empty
+	Silent     bool   `json:"silent"`          // deliver without sound
+	Digest     bool   `json:"digest"`          // receive digests instead of individual alerts
+	Escalation bool   `json:"escalation"`      // receive only escalated alerts
 }
 
-// ProxyConfig stores proxy settings
+// SystemConfig stores global key/value settings
 type SystemConfig struct {
 	gorm.Model
 	Key   string `json:"key" gorm:"uniqueIndex"`
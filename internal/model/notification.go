@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// NotificationStatus is the delivery state of an outbox entry
+type NotificationStatus string
+
+const (
+	NotificationPending NotificationStatus = "pending"
+	NotificationSent    NotificationStatus = "sent"
+	NotificationFailed  NotificationStatus = "failed" // retries exhausted
+)
+
+// Notification is an alert in the outbox. It is written together with the
+// monitor status change and delivered by the dispatcher with retries.
+type Notification struct {
+	gorm.Model
+	UserID        uint               `json:"userId" gorm:"index"`
+	MonitorID     uint               `json:"monitorId" gorm:"index"`
+	AppID         string             `json:"appId" gorm:"index"` // for deduplication across monitors of the app
+	Channel       string             `json:"channel"`
+	Title         string             `json:"title"`
+	Payload       string             `json:"-" gorm:"type:text"` // JSON encoded notify.Message
+	Status        NotificationStatus `json:"status" gorm:"index"`
+	Attempts      int                `json:"attempts"`
+	NextAttemptAt time.Time          `json:"nextAttemptAt" gorm:"index"`
+	LastError     string             `json:"lastError"`
+	SentAt        *time.Time         `json:"sentAt"`
+	Delivered     []string           `json:"delivered" gorm:"serializer:json"` // recipients reached so far, skipped by retries
+}
@@ -0,0 +1,132 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// QuietMode decides what happens to alerts raised during quiet hours
+type QuietMode string
+
+const (
+	QuietQueue QuietMode = "queue" // deliver when quiet hours end
+	QuietDrop  QuietMode = "drop"  // discard
+)
+
+// QuietHoursOff disables quiet hours of the global policy for a monitor
+const QuietHoursOff = "off"
+
+// NotifyPolicy limits how often a monitor alerts. Zero values of a monitor's
+// policy fall back to the global policy.
+type NotifyPolicy struct {
+	MinRepeatInterval int       `json:"minRepeatInterval"` // seconds between two alerts
+	MaxPerHour        int       `json:"maxPerHour"`
+	QuietHours        string    `json:"quietHours"` // "22:00-07:00"
+	QuietMode         QuietMode `json:"quietMode"`
+	Timezone          string    `json:"timezone"` // IANA name, server local time when empty
+}
+
+// Merge returns p with unset values taken from defaults
+func (p NotifyPolicy) Merge(defaults NotifyPolicy) NotifyPolicy {
+	if p.MinRepeatInterval == 0 {
+		p.MinRepeatInterval = defaults.MinRepeatInterval
+	}
+	if p.MaxPerHour == 0 {
+		p.MaxPerHour = defaults.MaxPerHour
+	}
+	if p.QuietHours == "" {
+		p.QuietHours = defaults.QuietHours
+	}
+	if p.QuietMode == "" {
+		p.QuietMode = defaults.QuietMode
+	}
+	if p.Timezone == "" {
+		p.Timezone = defaults.Timezone
+	}
+	return p
+}
+
+// Validate reports malformed quiet hours, modes and time zones
+func (p NotifyPolicy) Validate() error {
+	if p.MinRepeatInterval < 0 || p.MaxPerHour < 0 {
+		return fmt.Errorf("minRepeatInterval and maxPerHour must not be negative")
+	}
+	if p.QuietHours != "" && p.QuietHours != QuietHoursOff {
+		if _, _, err := parseQuietHours(p.QuietHours); err != nil {
+			return err
+		}
+	}
+	if p.QuietMode != "" && p.QuietMode != QuietQueue && p.QuietMode != QuietDrop {
+		return fmt.Errorf("invalid quiet mode %q", p.QuietMode)
+	}
+	if _, err := p.location(); err != nil {
+		return fmt.Errorf("invalid timezone %q", p.Timezone)
+	}
+	return nil
+}
+
+func (p NotifyPolicy) location() (*time.Location, error) {
+	return loadLocation(p.Timezone)
+}
+
+// loadLocation loads an IANA time zone, the server's for an empty name
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(name)
+}
+
+// QuietUntil returns the end of the quiet period t falls in, or the zero time
+// when t is outside quiet hours
+func (p NotifyPolicy) QuietUntil(t time.Time) time.Time {
+	if p.QuietHours == "" || p.QuietHours == QuietHoursOff {
+		return time.Time{}
+	}
+	start, end, err := parseQuietHours(p.QuietHours)
+	if err != nil || start == end {
+		return time.Time{}
+	}
+	loc, err := p.location()
+	if err != nil {
+		return time.Time{}
+	}
+
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	quiet := minute >= start && minute < end
+	if start > end { // spans midnight
+		quiet = minute >= start || minute < end
+	}
+	if !quiet {
+		return time.Time{}
+	}
+
+	until := time.Date(local.Year(), local.Month(), local.Day(), end/60, end%60, 0, 0, loc)
+	if !until.After(local) {
+		until = until.AddDate(0, 0, 1)
+	}
+	return until
+}
+
+// parseQuietHours parses "HH:MM-HH:MM" into minutes since midnight
+func parseQuietHours(s string) (start, end int, err error) {
+	from, to, found := strings.Cut(s, "-")
+	if start, err = parseClock(from); err == nil && found {
+		end, err = parseClock(to)
+	}
+	if err != nil || !found {
+		return 0, 0, fmt.Errorf("invalid quiet hours %q, expected HH:MM-HH:MM", s)
+	}
+	return start, end, nil
+}
+
+// parseClock parses "HH:MM" into minutes since midnight
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
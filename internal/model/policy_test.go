@@ -0,0 +1,91 @@
+package model
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata" // the zones below, wherever the tests run
+)
+
+func TestQuietUntil(t *testing.T) {
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	at := func(loc *time.Location, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2024, month, day, hour, minute, 0, 0, loc)
+	}
+
+	tests := []struct {
+		name   string
+		policy NotifyPolicy
+		t      time.Time
+		want   time.Time // zero outside quiet hours
+	}{
+		{"none", NotifyPolicy{}, at(shanghai, 1, 1, 23, 0), time.Time{}},
+		{"off", NotifyPolicy{QuietHours: QuietHoursOff}, at(shanghai, 1, 1, 23, 0), time.Time{}},
+		{"invalid", NotifyPolicy{QuietHours: "late"}, at(shanghai, 1, 1, 23, 0), time.Time{}},
+		{"empty range", NotifyPolicy{QuietHours: "22:00-22:00"}, at(shanghai, 1, 1, 22, 0), time.Time{}},
+
+		{"before, same day", NotifyPolicy{QuietHours: "12:00-14:00", Timezone: "Asia/Shanghai"}, at(shanghai, 1, 1, 11, 59), time.Time{}},
+		{"start, same day", NotifyPolicy{QuietHours: "12:00-14:00", Timezone: "Asia/Shanghai"}, at(shanghai, 1, 1, 12, 0), at(shanghai, 1, 1, 14, 0)},
+		{"end, same day", NotifyPolicy{QuietHours: "12:00-14:00", Timezone: "Asia/Shanghai"}, at(shanghai, 1, 1, 14, 0), time.Time{}},
+
+		{"evening, over midnight", NotifyPolicy{QuietHours: "22:00-07:00", Timezone: "Asia/Shanghai"}, at(shanghai, 1, 1, 23, 30), at(shanghai, 1, 2, 7, 0)},
+		{"morning, over midnight", NotifyPolicy{QuietHours: "22:00-07:00", Timezone: "Asia/Shanghai"}, at(shanghai, 1, 2, 6, 59), at(shanghai, 1, 2, 7, 0)},
+		{"day, over midnight", NotifyPolicy{QuietHours: "22:00-07:00", Timezone: "Asia/Shanghai"}, at(shanghai, 1, 2, 12, 0), time.Time{}},
+		{"year end, over midnight", NotifyPolicy{QuietHours: "22:00-07:00", Timezone: "Asia/Shanghai"}, at(shanghai, 12, 31, 22, 0), time.Date(2025, 1, 1, 7, 0, 0, 0, shanghai)},
+
+		// The same instant, read in the zone of the policy
+		{"other zone, quiet", NotifyPolicy{QuietHours: "22:00-07:00", Timezone: "Asia/Shanghai"}, at(time.UTC, 1, 1, 15, 0), at(shanghai, 1, 2, 7, 0)},
+		{"other zone, not quiet", NotifyPolicy{QuietHours: "22:00-07:00", Timezone: "Europe/Berlin"}, at(time.UTC, 1, 1, 15, 0), time.Time{}},
+
+		// Clocks go forward at 02:00 on March 31 in Berlin
+		{"daylight saving", NotifyPolicy{QuietHours: "22:00-07:00", Timezone: "Europe/Berlin"}, at(berlin, 3, 30, 23, 0), at(berlin, 3, 31, 7, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.policy.QuietUntil(tt.t)
+			if !got.Equal(tt.want) {
+				t.Errorf("QuietUntil(%s) = %s, want %s", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotifyPolicyMerge(t *testing.T) {
+	global := NotifyPolicy{MinRepeatInterval: 60, MaxPerHour: 5, QuietHours: "22:00-07:00", QuietMode: QuietQueue, Timezone: "Asia/Shanghai"}
+
+	if got := (NotifyPolicy{}).Merge(global); got != global {
+		t.Errorf("empty policy = %+v, want the global one", got)
+	}
+	own := NotifyPolicy{MaxPerHour: 1, QuietHours: QuietHoursOff}
+	got := own.Merge(global)
+	want := NotifyPolicy{MinRepeatInterval: 60, MaxPerHour: 1, QuietHours: QuietHoursOff, QuietMode: QuietQueue, Timezone: "Asia/Shanghai"}
+	if got != want {
+		t.Errorf("Merge = %+v, want %+v", got, want)
+	}
+	if !got.QuietUntil(time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC)).IsZero() {
+		t.Error("quiet hours turned off by the monitor still apply")
+	}
+}
+
+func TestNotifyPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  NotifyPolicy
+		wantErr bool
+	}{
+		{"empty", NotifyPolicy{}, false},
+		{"full", NotifyPolicy{MinRepeatInterval: 60, MaxPerHour: 5, QuietHours: "22:00-07:00", QuietMode: QuietDrop, Timezone: "Europe/Berlin"}, false},
+		{"off", NotifyPolicy{QuietHours: QuietHoursOff}, false},
+		{"negative interval", NotifyPolicy{MinRepeatInterval: -1}, true},
+		{"negative limit", NotifyPolicy{MaxPerHour: -1}, true},
+		{"no end", NotifyPolicy{QuietHours: "22:00"}, true},
+		{"bad clock", NotifyPolicy{QuietHours: "25:00-07:00"}, true},
+		{"unknown mode", NotifyPolicy{QuietMode: "later"}, true},
+		{"unknown zone", NotifyPolicy{Timezone: "Mars/Olympus"}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.policy.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// DefaultRoute receives the alerts of monitors that name no routes. Targets
+// without a group belong to it.
+const DefaultRoute = "default"
+
+// Route returns the group the target belongs to
+func (t TelegramTarget) Route() string {
+	if t.Group == "" {
+		return DefaultRoute
+	}
+	return t.Group
+}
+
+// AlertRoutes returns the target groups that receive alerts of the monitor
+func (m *Monitor) AlertRoutes() []string {
+	if len(m.Routes) == 0 {
+		return []string{DefaultRoute}
+	}
+	return m.Routes
+}
+
+// HasRoute reports whether any target belongs to the group
+func (c *TelegramConfig) HasRoute(route string) bool {
+	return slices.ContainsFunc(c.Targets, func(t TelegramTarget) bool { return t.Route() == route })
+}
+
+// NormalizeRoutes trims route names and drops empty and repeated ones
+func NormalizeRoutes(routes []string) ([]string, error) {
+	result := make([]string, 0, len(routes))
+	for _, route := range routes {
+		route = strings.TrimSpace(route)
+		if route == "" || slices.Contains(result, route) {
+			continue
+		}
+		if len(route) > 64 {
+			return nil, fmt.Errorf("route %q is too long", route)
+		}
+		result = append(result, route)
+	}
+	return result, nil
+}
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"database/sql/driver"
+	"fmt"
+
+	"tf-monitor/internal/secret"
+)
+
+// EncryptedString is a string column that is encrypted at rest
+type EncryptedString string
+
+// Value encrypts the string before it is written
+func (s EncryptedString) Value() (driver.Value, error) {
+	return secret.Encrypt(string(s))
+}
+
+// Scan decrypts the stored value
+func (s *EncryptedString) Scan(value interface{}) error {
+	var raw string
+	switch v := value.(type) {
+	case nil:
+		*s = ""
+		return nil
+	case string:
+		raw = v
+	case []byte:
+		raw = string(v)
+	default:
+		return fmt.Errorf("unsupported type %T for EncryptedString", value)
+	}
+
+	plaintext, err := secret.Decrypt(raw)
+	if err != nil {
+		return err
+	}
+	*s = EncryptedString(plaintext)
+	return nil
+}
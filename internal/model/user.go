@@ -0,0 +1,59 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Role controls what a user is allowed to do
+type Role string
+
+const (
+	RoleAdmin  Role = "admin"  // manage users, global settings and every monitor
+	RoleEditor Role = "editor" // create and manage own monitors
+	RoleViewer Role = "viewer" // read-only access to the monitors of all users
+)
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	switch r {
+	case RoleAdmin, RoleEditor, RoleViewer:
+		return true
+	}
+	return false
+}
+
+// User is an account that owns monitors and notification settings
+type User struct {
+	gorm.Model
+	Username     string `json:"username" gorm:"uniqueIndex"`
+	PasswordHash string `json:"-"` // Empty for accounts that sign in through SSO
+	Role         Role   `json:"role" gorm:"default:editor"`
+	ExternalID   string `json:"-" gorm:"index"` // Identity at an external provider, e.g. OIDC issuer and subject
+}
+
+// IsAdmin reports whether the user has the admin role
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// CanEdit reports whether the user may create and change monitors
+func (u *User) CanEdit() bool {
+	return u.Role == RoleAdmin || u.Role == RoleEditor
+}
+
+// CanViewAll reports whether the user may read monitors owned by others.
+// Viewers own no monitors, they watch those of the team, editors only need
+// their own.
+func (u *User) CanViewAll() bool {
+	return u.Role == RoleAdmin || u.Role == RoleViewer
+}
+
+// Session is a login issued to a browser or API client
+type Session struct {
+	gorm.Model
+	TokenHash string    `gorm:"uniqueIndex"` // SHA-256 of the token handed to the client
+	UserID    uint      `gorm:"index"`
+	ExpiresAt time.Time `gorm:"index"`
+}
@@ -0,0 +1,21 @@
+package model
+
+import "testing"
+
+func TestUserPermissions(t *testing.T) {
+	tests := []struct {
+		role                       Role
+		admin, canEdit, canViewAll bool
+	}{
+		{RoleAdmin, true, true, true},
+		{RoleEditor, false, true, false},
+		{RoleViewer, false, false, true},
+		{"", false, false, false},
+	}
+	for _, tt := range tests {
+		u := &User{Role: tt.role}
+		if u.IsAdmin() != tt.admin || u.CanEdit() != tt.canEdit || u.CanViewAll() != tt.canViewAll {
+			t.Errorf("role %q: IsAdmin %v, CanEdit %v, CanViewAll %v", tt.role, u.IsAdmin(), u.CanEdit(), u.CanViewAll())
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Webhook is an endpoint a user registered to receive events of their monitors
+type Webhook struct {
+	gorm.Model
+	UserID      uint            `json:"userId" gorm:"index"`
+	URL         string          `json:"url"`
+	Secret      EncryptedString `json:"-"`                             // signs the payloads
+	Events      []string        `json:"events" gorm:"serializer:json"` // event types delivered
+	Active      bool            `json:"active"`
+	Description string          `json:"description"`
+}
+
+// Subscribes reports whether the webhook receives events of type event
+func (w *Webhook) Subscribes(event string) bool {
+	return slices.Contains(w.Events, event)
+}
+
+// WebhookDelivery is an event sent to a webhook. Like notifications it is
+// written together with the change it reports and delivered with retries.
+type WebhookDelivery struct {
+	gorm.Model
+	WebhookID      uint               `json:"webhookId" gorm:"index"`
+	UserID         uint               `json:"userId" gorm:"index"`
+	MonitorID      uint               `json:"monitorId"`
+	GUID           string             `json:"guid" gorm:"index"` // shared by redeliveries of the same event
+	Event          string             `json:"event"`
+	Payload        string             `json:"payload" gorm:"type:text"`
+	Redelivery     bool               `json:"redelivery"`
+	Status         NotificationStatus `json:"status" gorm:"index"`
+	Attempts       int                `json:"attempts"`
+	NextAttemptAt  time.Time          `json:"nextAttemptAt" gorm:"index"`
+	ResponseStatus int                `json:"responseStatus"` // HTTP status of the last attempt, 0 without response
+	LastError      string             `json:"lastError"`
+	DeliveredAt    *time.Time         `json:"deliveredAt"`
+}
@@ -1,8 +1,10 @@
 package repository
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"tf-monitor/internal/model"
 
@@ -28,12 +30,145 @@ func InitDB(dbPath string) error {
 		return err
 	}
 
+	if err := migrateMonitorURLs(); err != nil {
+		return err
+	}
+
 	// Auto migrate tables
-	return DB.AutoMigrate(
+	if err := DB.AutoMigrate(
 		&model.Monitor{},
 		&model.TelegramConfig{},
 		&model.SystemConfig{},
-	)
+		&model.User{},
+		&model.Session{},
+		&model.Notification{},
+		&model.MonitorEvent{},
+		&model.Webhook{},
+		&model.WebhookDelivery{},
+	); err != nil {
+		return err
+	}
+
+	if err := migrateTelegramTargets(); err != nil {
+		return err
+	}
+	return dropWebhookResponseBodies()
+}
+
+// dropWebhookResponseBodies removes the response bodies older versions kept
+// in the delivery history, only the status is stored now
+func dropWebhookResponseBodies() error {
+	if !DB.Migrator().HasColumn(&model.WebhookDelivery{}, "response_body") {
+		return nil
+	}
+	return DB.Migrator().DropColumn(&model.WebhookDelivery{}, "response_body")
+}
+
+// migrateMonitorURLs lifts the unique constraint older databases have on the
+// link of a monitor, links are unique per user since monitors have owners.
+// SQLite cannot drop the constraint, so the table is rebuilt. Runs before
+// AutoMigrate, which adds the columns of the current model.
+func migrateMonitorURLs() error {
+	if !DB.Migrator().HasTable(&model.Monitor{}) {
+		return nil
+	}
+	legacy, err := hasUniqueIndex("monitors", "test_flight_url")
+	if err != nil || !legacy {
+		return err
+	}
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		// Index names are global in SQLite, those of the old table go first
+		var indexes []string
+		if err := tx.Raw("SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = 'monitors' AND sql IS NOT NULL").
+			Scan(&indexes).Error; err != nil {
+			return err
+		}
+		for _, name := range indexes {
+			if err := tx.Exec("DROP INDEX " + quote(name)).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Exec("ALTER TABLE monitors RENAME TO monitors__old").Error; err != nil {
+			return err
+		}
+		if err := tx.Migrator().CreateTable(&model.Monitor{}); err != nil {
+			return err
+		}
+		// Columns the model no longer has are left behind
+		var columns []string
+		if err := tx.Raw("SELECT name FROM pragma_table_info('monitors__old') WHERE name IN (SELECT name FROM pragma_table_info('monitors'))").
+			Scan(&columns).Error; err != nil {
+			return err
+		}
+		for i, name := range columns {
+			columns[i] = quote(name)
+		}
+		list := strings.Join(columns, ", ")
+		for _, stmt := range []string{
+			"INSERT INTO monitors (" + list + ") SELECT " + list + " FROM monitors__old",
+			"DROP TABLE monitors__old",
+		} {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// hasUniqueIndex reports whether table has a unique index or constraint on
+// column alone
+func hasUniqueIndex(table, column string) (bool, error) {
+	var indexes []struct {
+		Name   string
+		Unique bool
+	}
+	if err := DB.Raw("SELECT name, \"unique\" FROM pragma_index_list(?)", table).Scan(&indexes).Error; err != nil {
+		return false, err
+	}
+	for _, index := range indexes {
+		if !index.Unique {
+			continue
+		}
+		var columns []string
+		if err := DB.Raw("SELECT name FROM pragma_index_info(?)", index.Name).Scan(&columns).Error; err != nil {
+			return false, err
+		}
+		if len(columns) == 1 && columns[0] == column {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// quote quotes an SQLite identifier
+func quote(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// migrateTelegramTargets moves the single chat_id of older databases into targets
+func migrateTelegramTargets() error {
+	migrator := DB.Migrator()
+	if !migrator.HasColumn(&model.TelegramConfig{}, "chat_id") {
+		return nil
+	}
+
+	var rows []struct {
+		ID     uint
+		ChatID string
+	}
+	if err := DB.Model(&model.TelegramConfig{}).Select("id, chat_id").Where("chat_id <> ''").Find(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		targets, _ := json.Marshal([]model.TelegramTarget{{ChatID: row.ChatID}})
+		if err := DB.Model(&model.TelegramConfig{}).Where("id = ?", row.ID).Update("targets", string(targets)).Error; err != nil {
+			return err
+		}
+	}
+	return migrator.DropColumn(&model.TelegramConfig{}, "chat_id")
 }
 
 func GetDB() *gorm.DB {
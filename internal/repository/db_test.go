@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"tf-monitor/internal/model"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// baselineSchema is the monitors table of databases created before monitors
+// had owners
+const baselineSchema = "CREATE TABLE `monitors` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`app_id` text,`app_name` text,`icon_url` text,`test_flight_url` text,`status` text DEFAULT \"checking\",`interval` integer DEFAULT 30,`duration` integer DEFAULT 24,`notify_mode` text DEFAULT \"once\",`enabled` numeric DEFAULT true,`notified` numeric DEFAULT false,`last_check` datetime,`last_error` text,`expire_at` datetime,CONSTRAINT `uni_monitors_test_flight_url` UNIQUE (`test_flight_url`));" +
+	"CREATE INDEX `idx_monitors_app_id` ON `monitors`(`app_id`);" +
+	"CREATE INDEX `idx_monitors_deleted_at` ON `monitors`(`deleted_at`);"
+
+const testURL = "https://testflight.apple.com/join/abcd1234"
+
+func TestMigrateMonitorURLs(t *testing.T) {
+	for name, schema := range map[string]string{
+		"named constraint": baselineSchema,
+		"column constraint": "CREATE TABLE `monitors` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime," +
+			"`app_id` text,`app_name` text,`test_flight_url` text UNIQUE,`enabled` numeric DEFAULT true);",
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tf-monitor.db")
+			raw, err := sql.Open("sqlite3", path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := raw.Exec(schema); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := raw.Exec("INSERT INTO monitors (app_id, app_name, test_flight_url, enabled) VALUES ('abcd1234', 'App', ?, false)", testURL); err != nil {
+				t.Fatal(err)
+			}
+			raw.Close()
+
+			if err := InitDB(path); err != nil {
+				t.Fatalf("InitDB: %v", err)
+			}
+			t.Cleanup(func() {
+				if db, err := DB.DB(); err == nil {
+					db.Close()
+				}
+			})
+
+			var kept model.Monitor
+			if err := DB.First(&kept).Error; err != nil {
+				t.Fatalf("migrated monitor: %v", err)
+			}
+			if kept.AppName != "App" || kept.TestFlightURL != testURL || kept.Enabled {
+				t.Errorf("migrated monitor = %+v", kept)
+			}
+
+			DB.Model(&kept).Update("user_id", 1)
+			if err := DB.Create(&model.Monitor{UserID: 2, AppID: "abcd1234", TestFlightURL: testURL}).Error; err != nil {
+				t.Errorf("second user cannot monitor the link: %v", err)
+			}
+			if err := DB.Create(&model.Monitor{UserID: 2, AppID: "abcd1234", TestFlightURL: testURL}).Error; err == nil {
+				t.Error("a user can monitor a link twice")
+			}
+
+			// Done once, later starts leave the table alone
+			if legacy, err := hasUniqueIndex("monitors", "test_flight_url"); err != nil || legacy {
+				t.Errorf("hasUniqueIndex = %v, %v", legacy, err)
+			}
+		})
+	}
+}
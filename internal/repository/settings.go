@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+
+	"tf-monitor/internal/model"
+	"tf-monitor/internal/secret"
+
+	"gorm.io/gorm"
+)
+
+// secretSettings lists system settings that are encrypted at rest
+var secretSettings = map[string]bool{
+	"proxy_url":   true,
+	"signing_key": true,
+}
+
+// GetSetting returns a system setting, or an empty string when it is unset
+func GetSetting(key string) (string, error) {
+	var cfg model.SystemConfig
+	if err := DB.Where("key = ?", key).First(&cfg).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+	if secretSettings[key] {
+		return secret.Decrypt(cfg.Value)
+	}
+	return cfg.Value, nil
+}
+
+// SetSetting creates or updates a system setting
+func SetSetting(key, value string) error {
+	if secretSettings[key] {
+		encrypted, err := secret.Encrypt(value)
+		if err != nil {
+			return err
+		}
+		value = encrypted
+	}
+
+	return DB.Where("key = ?", key).Assign(model.SystemConfig{
+		Key:   key,
+		Value: value,
+	}).FirstOrCreate(&model.SystemConfig{}).Error
+}
+
+// SigningKey returns the key links sent in notifications are signed with. It
+// is generated on first use.
+func SigningKey() ([]byte, error) {
+	value, err := GetSetting("signing_key")
+	if err != nil {
+		return nil, err
+	}
+	if value == "" {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		value = hex.EncodeToString(key)
+		if err := SetSetting("signing_key", value); err != nil {
+			return nil, err
+		}
+	}
+	return hex.DecodeString(value)
+}
+
+// ReencryptSecrets rewrites every secret with the current master key. It
+// encrypts values stored before a key was configured and moves values
+// encrypted with a previous key to the current one.
+func ReencryptSecrets() error {
+	var telegramCfgs []model.TelegramConfig
+	if err := DB.Find(&telegramCfgs).Error; err != nil {
+		return err
+	}
+	for _, cfg := range telegramCfgs {
+		if err := DB.Model(&cfg).Update("bot_token", cfg.BotToken).Error; err != nil {
+			return err
+		}
+	}
+
+	var webhooks []model.Webhook
+	if err := DB.Find(&webhooks).Error; err != nil {
+		return err
+	}
+	for _, w := range webhooks {
+		if err := DB.Model(&w).Update("secret", w.Secret).Error; err != nil {
+			return err
+		}
+	}
+
+	for key := range secretSettings {
+		value, err := GetSetting(key)
+		if err != nil {
+			return err
+		}
+		if value == "" {
+			continue
+		}
+		if err := SetSetting(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+// Package secret encrypts sensitive values before they are written to the
+// database. Values are sealed with AES-256-GCM under a master key and tagged
+// with the key ID, so older keys can still be read after rotation.
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const prefix = "enc:v1:"
+
+var ErrUnknownKey = errors.New("secret was encrypted with an unknown key")
+
+type key struct {
+	id   string
+	aead cipher.AEAD
+}
+
+var (
+	mu       sync.RWMutex
+	current  *key
+	keysByID = map[string]*key{}
+)
+
+// Configure sets the master key used for new values and the previous keys that
+// are still accepted for decryption. An empty current key disables encryption.
+func Configure(currentKey string, previousKeys []string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	current = nil
+	keysByID = map[string]*key{}
+
+	for _, material := range previousKeys {
+		k, err := newKey(material)
+		if err != nil {
+			return err
+		}
+		keysByID[k.id] = k
+	}
+
+	if currentKey == "" {
+		return nil
+	}
+	k, err := newKey(currentKey)
+	if err != nil {
+		return err
+	}
+	keysByID[k.id] = k
+	current = k
+	return nil
+}
+
+// Enabled reports whether a master key is configured
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current != nil
+}
+
+// Encrypt seals plaintext with the current key, it is returned unchanged when
+// no key is configured
+func Encrypt(plaintext string) (string, error) {
+	mu.RLock()
+	k := current
+	mu.RUnlock()
+	if k == nil || plaintext == "" {
+		return plaintext, nil
+	}
+
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := k.aead.Seal(nonce, nonce, []byte(plaintext), []byte(k.id))
+	return prefix + k.id + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value produced by Encrypt, values stored before encryption
+// was enabled are returned as is
+func Decrypt(value string) (string, error) {
+	if !strings.HasPrefix(value, prefix) {
+		return value, nil
+	}
+
+	id, encoded, ok := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	if !ok {
+		return "", errors.New("malformed encrypted secret")
+	}
+
+	mu.RLock()
+	k := keysByID[id]
+	mu.RUnlock()
+	if k == nil {
+		return "", fmt.Errorf("%w %s", ErrUnknownKey, id)
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < k.aead.NonceSize() {
+		return "", errors.New("malformed encrypted secret")
+	}
+	nonce, ciphertext := sealed[:k.aead.NonceSize()], sealed[k.aead.NonceSize():]
+	plaintext, err := k.aead.Open(nil, nonce, ciphertext, []byte(k.id))
+	if err != nil {
+		return "", fmt.Errorf("decrypt secret: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// Redact hides all but the last four characters of a secret
+func Redact(value string) string {
+	if value == "" {
+		return ""
+	}
+	if len(value) <= 4 {
+		return "****"
+	}
+	return "****" + value[len(value)-4:]
+}
+
+// IsRedacted reports whether value is the output of Redact, used to keep the
+// stored secret when a client submits the redacted form back
+func IsRedacted(value string) bool {
+	return strings.HasPrefix(value, "****")
+}
+
+func newKey(material string) (*key, error) {
+	derived := sha256.Sum256([]byte(material))
+	block, err := aes.NewCipher(derived[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint := sha256.Sum256(derived[:])
+	return &key{id: hex.EncodeToString(fingerprint[:4]), aead: aead}, nil
+}
@@ -0,0 +1,44 @@
+package secret
+
+import (
+	"net/url"
+	"strings"
+)
+
+// RedactURL hides the password of a URL with credentials
+func RedactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	password, ok := u.User.Password()
+	if !ok {
+		return raw
+	}
+
+	// Build the userinfo by hand so the mask is not percent-encoded
+	userinfo := url.User(u.User.Username()).String() + ":" + Redact(password)
+	u.User = nil
+	return u.Scheme + "://" + userinfo + "@" + strings.TrimPrefix(u.String(), u.Scheme+"://")
+}
+
+// RestoreURL puts the stored password back into a submitted URL whose
+// password is still in redacted form
+func RestoreURL(submitted, existing string) string {
+	u, err := url.Parse(submitted)
+	if err != nil || u.User == nil {
+		return submitted
+	}
+	password, ok := u.User.Password()
+	if !ok || !IsRedacted(password) {
+		return submitted
+	}
+
+	old, err := url.Parse(existing)
+	if err != nil || old.User == nil {
+		return submitted
+	}
+	oldPassword, _ := old.User.Password()
+	u.User = url.UserPassword(u.User.Username(), oldPassword)
+	return u.String()
+}
@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"tf-monitor/internal/model"
+	"tf-monitor/internal/repository"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrInvalidSession     = errors.New("session invalid or expired")
+)
+
+// HashPassword returns the bcrypt hash of a password
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Authenticate verifies a username and password pair
+func Authenticate(username, password string) (*model.User, error) {
+	var user model.User
+	if err := repository.GetDB().Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	if user.PasswordHash == "" {
+		return nil, ErrInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return &user, nil
+}
+
+// CreateSession issues a new session token for the user
+func CreateSession(userID uint, ttl time.Duration) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	session := model.Session{
+		TokenHash: hashToken(token),
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := repository.GetDB().Create(&session).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// LookupSession resolves a session token to its user
+func LookupSession(token string) (*model.User, error) {
+	if token == "" {
+		return nil, ErrInvalidSession
+	}
+
+	var session model.Session
+	if err := repository.GetDB().Where("token_hash = ?", hashToken(token)).First(&session).Error; err != nil {
+		return nil, ErrInvalidSession
+	}
+	if time.Now().After(session.ExpiresAt) {
+		repository.GetDB().Unscoped().Delete(&session)
+		return nil, ErrInvalidSession
+	}
+
+	var user model.User
+	if err := repository.GetDB().First(&user, session.UserID).Error; err != nil {
+		return nil, ErrInvalidSession
+	}
+	return &user, nil
+}
+
+// DeleteSession revokes a session token
+func DeleteSession(token string) {
+	repository.GetDB().Unscoped().Where("token_hash = ?", hashToken(token)).Delete(&model.Session{})
+}
+
+// Bootstrap creates the initial admin account when no users exist yet and
+// assigns monitors and settings created before multi-user support to it
+func Bootstrap(username, password string) error {
+	db := repository.GetDB()
+
+	var count int64
+	db.Model(&model.User{}).Count(&count)
+	if count > 0 {
+		return nil
+	}
+
+	generated := password == ""
+	if generated {
+		token, err := randomToken()
+		if err != nil {
+			return err
+		}
+		password = token[:16]
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	admin := model.User{
+		Username:     username,
+		PasswordHash: hash,
+		Role:         model.RoleAdmin,
+	}
+	if err := db.Create(&admin).Error; err != nil {
+		return err
+	}
+	if generated {
+		// Printed once outside the log, which is often shipped elsewhere
+		fmt.Fprintf(os.Stderr, "Created admin user %q with generated password: %s\n", username, password)
+	}
+
+	db.Model(&model.Monitor{}).Where("user_id IS NULL OR user_id = 0").Update("user_id", admin.ID)
+	db.Model(&model.TelegramConfig{}).Where("user_id IS NULL OR user_id = 0").Update("user_id", admin.ID)
+	return nil
+}
+
+// MapRole picks the most privileged role matched by any of the values,
+// falling back to defaultRole when nothing matches
+func MapRole(values []string, mapping map[string]string, defaultRole string) model.Role {
+	matched := map[model.Role]bool{}
+	for _, value := range values {
+		if role, ok := mapping[value]; ok {
+			matched[model.Role(role)] = true
+		}
+	}
+
+	for _, role := range []model.Role{model.RoleAdmin, model.RoleEditor, model.RoleViewer} {
+		if matched[role] {
+			return role
+		}
+	}
+
+	if role := model.Role(defaultRole); role.Valid() {
+		return role
+	}
+	return model.RoleViewer
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
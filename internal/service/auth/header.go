@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"tf-monitor/internal/config"
+	"tf-monitor/internal/model"
+	"tf-monitor/internal/repository"
+)
+
+// TrustedHeader authenticates requests by a user header set by a reverse
+// proxy such as Authelia or oauth2-proxy
+type TrustedHeader struct {
+	cfg  config.TrustedHeaderConfig
+	nets []*net.IPNet
+}
+
+// NewTrustedHeader parses the trusted proxy ranges, plain IPs are accepted too
+func NewTrustedHeader(cfg config.TrustedHeaderConfig) (*TrustedHeader, error) {
+	t := &TrustedHeader{cfg: cfg}
+	for _, cidr := range cfg.TrustedCIDRs {
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted CIDR %q: %w", cidr, err)
+		}
+		t.nets = append(t.nets, ipNet)
+	}
+	return t, nil
+}
+
+// UserHeader returns the name of the header carrying the username
+func (t *TrustedHeader) UserHeader() string {
+	return t.cfg.UserHeader
+}
+
+// GroupsHeader returns the name of the header carrying the groups
+func (t *TrustedHeader) GroupsHeader() string {
+	return t.cfg.GroupsHeader
+}
+
+// Trusted reports whether a request from remoteAddr may set the user header.
+// The TCP peer is used on purpose, forwarded-for headers can be spoofed.
+func (t *TrustedHeader) Trusted(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range t.nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrUsernameTaken is returned when the proxy names a user whose username is
+// held by a local or single sign-on account
+var ErrUsernameTaken = errors.New("username is already used by another account")
+
+// ResolveUser finds or creates the user named by the proxy. Proxy users are
+// kept apart from other accounts of the same name. When the proxy sends
+// groups the role is synced from the group mapping.
+func (t *TrustedHeader) ResolveUser(username, groupsHeader string) (*model.User, error) {
+	var groups []string
+	for _, g := range strings.Split(groupsHeader, ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			groups = append(groups, g)
+		}
+	}
+	role := MapRole(groups, t.cfg.RoleMapping, t.cfg.DefaultRole)
+	externalID := "header:" + username
+
+	db := repository.GetDB()
+	var user model.User
+	if err := db.Where("external_id = ?", externalID).First(&user).Error; err == nil {
+		if len(groups) > 0 && user.Role != role {
+			db.Model(&user).Update("role", role)
+		}
+		return &user, nil
+	}
+
+	var existing model.User
+	if db.Where("username = ?", username).First(&existing).Error == nil {
+		return nil, ErrUsernameTaken
+	}
+
+	user = model.User{
+		Username:   username,
+		Role:       role,
+		ExternalID: externalID,
+	}
+	if err := db.Create(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
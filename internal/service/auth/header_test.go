@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"tf-monitor/internal/config"
+	"tf-monitor/internal/model"
+	"tf-monitor/internal/repository"
+)
+
+func TestTrustedHeaderTrusted(t *testing.T) {
+	th, err := NewTrustedHeader(config.TrustedHeaderConfig{
+		TrustedCIDRs: []string{"10.0.0.0/8", "192.168.1.5", "fd00::/8", "::1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		remoteAddr string
+		want       bool
+	}{
+		{"10.1.2.3:51234", true},
+		{"10.1.2.3", true},
+		{"11.1.2.3:51234", false},
+		{"192.168.1.5:443", true},
+		{"192.168.1.6:443", false},
+		{"[::ffff:10.1.2.3]:51234", true}, // IPv4 peer on a dual-stack socket
+		{"[fd12::1]:8080", true},
+		{"[fe80::1]:8080", false},
+		{"[::1]:8080", true},
+		{"127.0.0.1:8080", false},
+		{"", false},
+		{"proxy.local:8080", false},
+	}
+	for _, tt := range tests {
+		if got := th.Trusted(tt.remoteAddr); got != tt.want {
+			t.Errorf("Trusted(%q) = %v, want %v", tt.remoteAddr, got, tt.want)
+		}
+	}
+}
+
+func TestTrustedHeaderNoCIDRs(t *testing.T) {
+	th, err := NewTrustedHeader(config.TrustedHeaderConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, addr := range []string{"127.0.0.1:1234", "[::1]:1234", "10.0.0.1:1234"} {
+		if th.Trusted(addr) {
+			t.Errorf("Trusted(%q) without trusted ranges", addr)
+		}
+	}
+}
+
+func TestNewTrustedHeaderInvalid(t *testing.T) {
+	for _, cidr := range []string{"10.0.0.0/33", "not-an-ip", "10.0.0/8"} {
+		if _, err := NewTrustedHeader(config.TrustedHeaderConfig{TrustedCIDRs: []string{cidr}}); err == nil {
+			t.Errorf("NewTrustedHeader accepted %q", cidr)
+		}
+	}
+}
+
+func TestMapRole(t *testing.T) {
+	mapping := map[string]string{"admins": "admin", "devs": "editor", "staff": "viewer"}
+	tests := []struct {
+		name        string
+		values      []string
+		defaultRole string
+		want        model.Role
+	}{
+		{"most privileged wins", []string{"staff", "admins", "devs"}, "viewer", model.RoleAdmin},
+		{"single match", []string{"devs"}, "viewer", model.RoleEditor},
+		{"no match", []string{"others"}, "editor", model.RoleEditor},
+		{"no values", nil, "viewer", model.RoleViewer},
+		{"invalid default", nil, "root", model.RoleViewer},
+		{"match is case sensitive", []string{"Admins"}, "viewer", model.RoleViewer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MapRole(tt.values, mapping, tt.defaultRole); got != tt.want {
+				t.Errorf("MapRole = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrustedHeaderResolveUser(t *testing.T) {
+	if err := repository.InitDB(filepath.Join(t.TempDir(), "tf-monitor.db")); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() {
+		if db, err := repository.GetDB().DB(); err == nil {
+			db.Close()
+		}
+	})
+	db := repository.GetDB()
+	for _, u := range []model.User{
+		{Username: "admin", PasswordHash: "hash", Role: model.RoleAdmin},
+		{Username: "sso", Role: model.RoleAdmin, ExternalID: "oidc:https://issuer#1"},
+	} {
+		if err := db.Create(&u).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	th, err := NewTrustedHeader(config.TrustedHeaderConfig{
+		RoleMapping: map[string]string{"devs": "editor"},
+		DefaultRole: "viewer",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Local and single sign-on accounts cannot be taken over by name
+	for _, name := range []string{"admin", "sso"} {
+		if user, err := th.ResolveUser(name, "devs"); !errors.Is(err, ErrUsernameTaken) {
+			t.Errorf("ResolveUser(%q) = %+v, %v, want ErrUsernameTaken", name, user, err)
+		}
+	}
+
+	created, err := th.ResolveUser("bob", "")
+	if err != nil || created.Role != model.RoleViewer || created.ExternalID != "header:bob" {
+		t.Fatalf("ResolveUser(bob) = %+v, %v", created, err)
+	}
+	again, err := th.ResolveUser("bob", "devs")
+	if err != nil || again.ID != created.ID {
+		t.Fatalf("ResolveUser(bob) again = %+v, %v", again, err)
+	}
+	var stored model.User
+	if err := db.First(&stored, created.ID).Error; err != nil || stored.Role != model.RoleEditor {
+		t.Errorf("role after sync = %q, %v", stored.Role, err)
+	}
+}
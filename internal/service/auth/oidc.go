@@ -0,0 +1,508 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"tf-monitor/internal/config"
+	"tf-monitor/internal/model"
+	"tf-monitor/internal/repository"
+)
+
+const (
+	pendingLoginTTL = 10 * time.Minute
+	clockSkew       = time.Minute // tolerated between us and the identity provider
+)
+
+var ErrInvalidState = errors.New("login state invalid or expired")
+
+// OIDCProvider implements the OpenID Connect authorization code flow with PKCE
+type OIDCProvider struct {
+	cfg    config.OIDCConfig
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]crypto.PublicKey
+	pending   map[string]pendingLogin
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type pendingLogin struct {
+	nonce     string
+	verifier  string
+	expiresAt time.Time
+}
+
+// NewOIDCProvider creates a provider, discovery runs lazily on first use so an
+// unreachable identity provider does not block startup
+func NewOIDCProvider(cfg config.OIDCConfig) *OIDCProvider {
+	return &OIDCProvider{
+		cfg:     cfg,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		keys:    make(map[string]crypto.PublicKey),
+		pending: make(map[string]pendingLogin),
+	}
+}
+
+// AuthCodeURL starts a login and returns the identity provider URL to redirect to
+func (p *OIDCProvider) AuthCodeURL() (string, error) {
+	d, err := p.getDiscovery()
+	if err != nil {
+		return "", err
+	}
+
+	state, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	verifier, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	p.mu.Lock()
+	now := time.Now()
+	for k, v := range p.pending {
+		if now.After(v.expiresAt) {
+			delete(p.pending, k)
+		}
+	}
+	p.pending[state] = pendingLogin{
+		nonce:     nonce,
+		verifier:  verifier,
+		expiresAt: now.Add(pendingLoginTTL),
+	}
+	p.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(verifier))
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + params.Encode(), nil
+}
+
+// Callback completes a login and returns the local user for the identity
+func (p *OIDCProvider) Callback(state, code string) (*model.User, error) {
+	p.mu.Lock()
+	login, ok := p.pending[state]
+	delete(p.pending, state)
+	p.mu.Unlock()
+	if !ok || time.Now().After(login.expiresAt) {
+		return nil, ErrInvalidState
+	}
+
+	rawIDToken, err := p.exchange(code, login.verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := p.verify(rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+	if nonce, _ := claims["nonce"].(string); nonce != login.nonce {
+		return nil, errors.New("id_token nonce mismatch")
+	}
+
+	return p.resolveUser(claims)
+}
+
+// getDiscovery returns the provider metadata. It is fetched without holding
+// the lock, so a slow identity provider does not hold up pending logins;
+// concurrent first logins may each fetch it.
+func (p *OIDCProvider) getDiscovery() (*oidcDiscovery, error) {
+	p.mu.Lock()
+	d := p.discovery
+	p.mu.Unlock()
+	if d != nil {
+		return d, nil
+	}
+
+	wellKnown := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	d = &oidcDiscovery{}
+	if err := p.getJSON(wellKnown, d); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if d.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer %q does not match configured %q", d.Issuer, p.cfg.Issuer)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery == nil {
+		p.discovery = d
+	}
+	return p.discovery, nil
+}
+
+func (p *OIDCProvider) exchange(code, verifier string) (string, error) {
+	d, err := p.getDiscovery()
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"client_id":     {p.cfg.ClientID},
+		"code_verifier": {verifier},
+	}
+
+	req, err := http.NewRequest("POST", d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("token endpoint returned %d", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		return "", fmt.Errorf("token endpoint: %s %s", body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", errors.New("token endpoint returned no id_token")
+	}
+	return body.IDToken, nil
+}
+
+// verify checks the signature and standard claims of an ID token
+func (p *OIDCProvider) verify(rawIDToken string) (map[string]interface{}, error) {
+	parts := strings.Split(rawIDToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed id_token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed id_token signature")
+	}
+
+	key, err := p.publicKey(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+
+	switch header.Alg {
+	case "RS256":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok || rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, digest[:], signature) != nil {
+			return nil, errors.New("invalid id_token signature")
+		}
+	case "ES256":
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(signature) != 64 {
+			return nil, errors.New("invalid id_token signature")
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(ecKey, digest[:], r, s) {
+			return nil, errors.New("invalid id_token signature")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported id_token algorithm %q", header.Alg)
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+
+	d, err := p.getDiscovery()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkClaims(claims, d.Issuer, p.cfg.ClientID, time.Now()); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// checkClaims validates the standard claims of an ID token issued by issuer
+// for clientID, as of now
+func checkClaims(claims map[string]interface{}, issuer, clientID string, now time.Time) error {
+	if iss, _ := claims["iss"].(string); iss != issuer {
+		return errors.New("id_token issuer mismatch")
+	}
+	audience := claimStrings(claims["aud"])
+	if !containsString(audience, clientID) {
+		return errors.New("id_token audience mismatch")
+	}
+	// The authorized party is required when the token is meant for others too
+	azp, hasAZP := claims["azp"].(string)
+	if (len(audience) > 1 && !hasAZP) || (hasAZP && azp != clientID) {
+		return errors.New("id_token authorized party mismatch")
+	}
+
+	exp, ok := claimTime(claims["exp"])
+	if !ok {
+		return errors.New("id_token has no expiry")
+	}
+	if now.After(exp.Add(clockSkew)) {
+		return errors.New("id_token expired")
+	}
+	iat, ok := claimTime(claims["iat"])
+	if !ok {
+		return errors.New("id_token has no issue time")
+	}
+	if iat.After(now.Add(clockSkew)) {
+		return errors.New("id_token issued in the future")
+	}
+	if _, present := claims["nbf"]; present {
+		nbf, ok := claimTime(claims["nbf"])
+		if !ok || nbf.After(now.Add(clockSkew)) {
+			return errors.New("id_token not yet valid")
+		}
+	}
+	return nil
+}
+
+// claimTime reads a NumericDate claim
+func claimTime(v interface{}) (time.Time, bool) {
+	seconds, ok := v.(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(seconds), 0), true
+}
+
+// publicKey returns the signing key with the given ID, refreshing the key set
+// once when the ID is unknown to pick up key rotation
+func (p *OIDCProvider) publicKey(kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	key, ok := p.keys[kid]
+	p.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	if err := p.refreshKeys(); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (p *OIDCProvider) refreshKeys() error {
+	d, err := p.getDiscovery()
+	if err != nil {
+		return err
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := p.getJSON(d.JWKSURI, &jwks); err != nil {
+		return fmt.Errorf("fetch jwks: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		case "EC":
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if errX != nil || errY != nil || k.Crv != "P-256" {
+				continue
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(x),
+				Y:     new(big.Int).SetBytes(y),
+			}
+		}
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+	return nil
+}
+
+// resolveUser finds or creates the local account for the identity and keeps
+// its role in sync with the configured claim mapping
+func (p *OIDCProvider) resolveUser(claims map[string]interface{}) (*model.User, error) {
+	iss, _ := claims["iss"].(string)
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return nil, errors.New("id_token has no subject")
+	}
+	externalID := "oidc:" + iss + "#" + sub
+
+	username, _ := claims[p.cfg.UsernameClaim].(string)
+	if username == "" {
+		username, _ = claims["email"].(string)
+	}
+	if username == "" {
+		username = sub
+	}
+
+	role := MapRole(claimStrings(claims[p.cfg.RoleClaim]), p.cfg.RoleMapping, p.cfg.DefaultRole)
+	db := repository.GetDB()
+
+	var user model.User
+	if err := db.Where("external_id = ?", externalID).First(&user).Error; err == nil {
+		if user.Role != role {
+			db.Model(&user).Update("role", role)
+		}
+		return &user, nil
+	}
+
+	var existing model.User
+	if db.Where("username = ?", username).First(&existing).Error == nil {
+		return nil, fmt.Errorf("username %q is already used by another account", username)
+	}
+
+	user = model.User{
+		Username:   username,
+		Role:       role,
+		ExternalID: externalID,
+	}
+	if err := db.Create(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (p *OIDCProvider) getJSON(u string, v interface{}) error {
+	resp, err := p.client.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return errors.New("malformed id_token")
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return errors.New("malformed id_token")
+	}
+	return nil
+}
+
+// claimStrings normalises a claim that may be a string or a list of strings
+func claimStrings(v interface{}) []string {
+	switch val := v.(type) {
+	case string:
+		return []string{val}
+	case []interface{}:
+		result := make([]string, 0, len(val))
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,240 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"tf-monitor/internal/config"
+)
+
+const testClientID = "tf-monitor"
+
+// testIssuer is an identity provider serving discovery and a key set with an
+// RSA key "rsa" and a P-256 key "ec"
+type testIssuer struct {
+	*httptest.Server
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iss := &testIssuer{rsaKey: rsaKey, ecKey: ecKey}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 iss.URL,
+			"authorization_endpoint": iss.URL + "/authorize",
+			"token_endpoint":         iss.URL + "/token",
+			"jwks_uri":               iss.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{
+			{
+				"kid": "rsa", "kty": "RSA", "use": "sig",
+				"n": b64(rsaKey.N.Bytes()),
+				"e": b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+			},
+			{
+				"kid": "ec", "kty": "EC", "use": "sig", "crv": "P-256",
+				"x": b64(ecKey.X.FillBytes(make([]byte, 32))),
+				"y": b64(ecKey.Y.FillBytes(make([]byte, 32))),
+			},
+			{"kid": "enc", "kty": "RSA", "use": "enc", "n": b64(rsaKey.N.Bytes()), "e": "AQAB"},
+		}})
+	})
+	iss.Server = httptest.NewServer(mux)
+	t.Cleanup(iss.Close)
+	return iss
+}
+
+func (iss *testIssuer) provider() *OIDCProvider {
+	return NewOIDCProvider(config.OIDCConfig{Issuer: iss.URL, ClientID: testClientID})
+}
+
+// claims returns valid claims of a token issued now
+func (iss *testIssuer) claims() map[string]interface{} {
+	now := time.Now()
+	return map[string]interface{}{
+		"iss": iss.URL,
+		"sub": "alice",
+		"aud": testClientID,
+		"iat": float64(now.Unix()),
+		"exp": float64(now.Add(time.Hour).Unix()),
+	}
+}
+
+// sign returns a token with the claims, signed as alg with the key kid. Other
+// algorithms get a dummy signature.
+func (iss *testIssuer) sign(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch alg {
+	case "RS256":
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, iss.rsaKey, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, iss.ecKey, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	default:
+		signature = []byte("signature")
+	}
+	return signed + "." + b64(signature)
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func TestVerify(t *testing.T) {
+	iss := newTestIssuer(t)
+
+	tamper := func(token string) string {
+		parts := strings.Split(token, ".")
+		claims := iss.claims()
+		claims["sub"] = "mallory"
+		payload, _ := json.Marshal(claims)
+		return parts[0] + "." + b64(payload) + "." + parts[2]
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{"RS256", iss.sign(t, "RS256", "rsa", iss.claims()), ""},
+		{"ES256", iss.sign(t, "ES256", "ec", iss.claims()), ""},
+		{"RS256 tampered payload", tamper(iss.sign(t, "RS256", "rsa", iss.claims())), "invalid id_token signature"},
+		{"ES256 tampered payload", tamper(iss.sign(t, "ES256", "ec", iss.claims())), "invalid id_token signature"},
+		{"RS256 with the EC key", iss.sign(t, "RS256", "ec", iss.claims()), "invalid id_token signature"},
+		{"ES256 with the RSA key", iss.sign(t, "ES256", "rsa", iss.claims()), "invalid id_token signature"},
+		{"alg none", iss.sign(t, "none", "rsa", iss.claims()), "unsupported id_token algorithm"},
+		{"alg HS256", iss.sign(t, "HS256", "rsa", iss.claims()), "unsupported id_token algorithm"},
+		{"unknown kid", iss.sign(t, "RS256", "other", iss.claims()), "unknown signing key"},
+		{"encryption key", iss.sign(t, "RS256", "enc", iss.claims()), "unknown signing key"},
+		{"two segments", "a.b", "malformed id_token"},
+		{"bad header", "!!!." + b64([]byte("{}")) + ".sig", "malformed id_token"},
+		{"wrong issuer", func() string {
+			claims := iss.claims()
+			claims["iss"] = "https://evil.example.com"
+			return iss.sign(t, "RS256", "rsa", claims)
+		}(), "issuer mismatch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := iss.provider().verify(tt.token)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("verify: %v", err)
+				}
+				if claims["sub"] != "alice" {
+					t.Errorf("sub = %v", claims["sub"])
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("verify error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckClaims(t *testing.T) {
+	const issuer = "https://id.example.com"
+	now := time.Unix(1700000000, 0)
+	at := func(d time.Duration) float64 { return float64(now.Add(d).Unix()) }
+
+	tests := []struct {
+		name    string
+		change  map[string]interface{} // nil values remove the claim
+		wantErr string
+	}{
+		{"valid", nil, ""},
+		{"wrong issuer", map[string]interface{}{"iss": "https://other.example.com"}, "issuer mismatch"},
+		{"missing issuer", map[string]interface{}{"iss": nil}, "issuer mismatch"},
+		{"wrong audience", map[string]interface{}{"aud": "other"}, "audience mismatch"},
+		{"audience list", map[string]interface{}{"aud": []interface{}{testClientID}}, ""},
+		{"audiences without azp", map[string]interface{}{"aud": []interface{}{testClientID, "other"}}, "authorized party mismatch"},
+		{"audiences with azp", map[string]interface{}{"aud": []interface{}{"other", testClientID}, "azp": testClientID}, ""},
+		{"audiences with other azp", map[string]interface{}{"aud": []interface{}{testClientID, "other"}, "azp": "other"}, "authorized party mismatch"},
+		{"single audience with other azp", map[string]interface{}{"azp": "other"}, "authorized party mismatch"},
+		{"expired", map[string]interface{}{"exp": at(-2 * time.Minute)}, "expired"},
+		{"expired within clock skew", map[string]interface{}{"exp": at(-30 * time.Second)}, ""},
+		{"missing expiry", map[string]interface{}{"exp": nil}, "no expiry"},
+		{"expiry not a number", map[string]interface{}{"exp": "tomorrow"}, "no expiry"},
+		{"issued in the future", map[string]interface{}{"iat": at(2 * time.Minute)}, "issued in the future"},
+		{"issued within clock skew", map[string]interface{}{"iat": at(30 * time.Second)}, ""},
+		{"missing issue time", map[string]interface{}{"iat": nil}, "no issue time"},
+		{"not yet valid", map[string]interface{}{"nbf": at(2 * time.Minute)}, "not yet valid"},
+		{"valid since", map[string]interface{}{"nbf": at(-time.Minute)}, ""},
+		{"nbf not a number", map[string]interface{}{"nbf": "now"}, "not yet valid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := map[string]interface{}{
+				"iss": issuer,
+				"aud": testClientID,
+				"iat": at(-time.Minute),
+				"exp": at(time.Hour),
+			}
+			for k, v := range tt.change {
+				if v == nil {
+					delete(claims, k)
+				} else {
+					claims[k] = v
+				}
+			}
+
+			err := checkClaims(claims, issuer, testClientID, now)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkClaims: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkClaims error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	iss := newTestIssuer(t)
+	p := NewOIDCProvider(config.OIDCConfig{Issuer: iss.URL + "/", ClientID: testClientID})
+	if _, err := p.getDiscovery(); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("getDiscovery error = %v", err)
+	}
+}
@@ -0,0 +1,438 @@
+// Package bot runs an interactive Telegram bot for managing monitors from chat
+package bot
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"tf-monitor/internal/config"
+	"tf-monitor/internal/logging"
+	"tf-monitor/internal/model"
+	"tf-monitor/internal/repository"
+	"tf-monitor/internal/service/manager"
+	"tf-monitor/internal/service/notify"
+	"tf-monitor/internal/service/scheduler"
+	"tf-monitor/internal/service/telegram"
+
+	"github.com/gin-gonic/gin"
+)
+
+const helpText = `TestFlight Monitor
+
+/add <url|code>... - start monitoring TestFlight links
+/list - list monitors
+/pause <id> - pause a monitor
+/resume <id> - resume a monitor
+/delete <id> - delete a monitor
+/status - scheduler status
+
+Pasting a TestFlight link adds it too.`
+
+var allowedUpdates = []string{"message", "callback_query"}
+
+var linkPattern = regexp.MustCompile(`https?://testflight\.apple\.com/join/[a-zA-Z0-9]+`)
+
+// Bot receives commands via long polling or a webhook. Only chats that are
+// configured as a user's Telegram chat are served, commands act as that user.
+// In group chats only the bot users of those settings may send commands.
+type Bot struct {
+	cfg      config.BotConfig
+	client   *telegram.Client
+	proxyURL string
+	stopChan chan struct{}
+}
+
+// New creates a bot, call Start to begin receiving updates
+func New(cfg config.BotConfig, proxyURL string) *Bot {
+	return &Bot{
+		cfg:      cfg,
+		client:   telegram.NewClient(cfg.Token, proxyURL),
+		proxyURL: proxyURL,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start registers the webhook or starts long polling
+func (b *Bot) Start() error {
+	if b.cfg.Mode == "webhook" {
+		payload := map[string]interface{}{
+			"url":             b.cfg.WebhookURL,
+			"secret_token":    b.cfg.WebhookSecret,
+			"allowed_updates": allowedUpdates,
+		}
+		if err := b.client.Call("setWebhook", payload, nil); err != nil {
+			return fmt.Errorf("set webhook: %w", err)
+		}
+		slog.Info("Telegram bot webhook registered")
+		return nil
+	}
+
+	// getUpdates is refused while a webhook is set
+	if err := b.client.Call("deleteWebhook", map[string]interface{}{}, nil); err != nil {
+		return fmt.Errorf("delete webhook: %w", err)
+	}
+	go b.poll()
+	slog.Info("Telegram bot polling started")
+	return nil
+}
+
+// Stop ends long polling
+func (b *Bot) Stop() {
+	close(b.stopChan)
+}
+
+func (b *Bot) poll() {
+	offset := 0
+	for {
+		select {
+		case <-b.stopChan:
+			return
+		default:
+		}
+
+		var updates []telegram.Update
+		err := b.client.Call("getUpdates", map[string]interface{}{
+			"offset":          offset,
+			"timeout":         30,
+			"allowed_updates": allowedUpdates,
+		}, &updates)
+		if err != nil {
+			slog.Warn("Telegram getUpdates failed", logging.Err(err))
+			select {
+			case <-b.stopChan:
+				return
+			case <-time.After(max(5*time.Second, telegram.RetryAfter(err))):
+			}
+			continue
+		}
+
+		for _, update := range updates {
+			offset = update.UpdateID + 1
+			b.handleUpdate(update)
+		}
+	}
+}
+
+// HandleWebhook receives updates pushed by Telegram in webhook mode
+func (b *Bot) HandleWebhook(c *gin.Context) {
+	token := c.GetHeader("X-Telegram-Bot-Api-Secret-Token")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(b.cfg.WebhookSecret)) != 1 {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	var update telegram.Update
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	b.handleUpdate(update)
+	c.Status(http.StatusOK)
+}
+
+func (b *Bot) handleUpdate(update telegram.Update) {
+	if update.CallbackQuery != nil {
+		b.handleCallback(update.CallbackQuery)
+		return
+	}
+
+	msg := update.Message
+	if msg == nil || msg.Text == "" {
+		return
+	}
+
+	user := authorizedUser(msg.Chat, msg.From)
+	if user == nil {
+		slog.Warn("Ignored Telegram message from unauthorized chat or sender", "chat_id", msg.Chat.ID)
+		return
+	}
+
+	b.reply(msg, b.execute(user, msg.Text))
+}
+
+// handleCallback applies an action button pressed on an alert and edits the
+// alert to show the new state
+func (b *Bot) handleCallback(q *telegram.CallbackQuery) {
+	if q.Message == nil {
+		b.answer(q.ID, "")
+		return
+	}
+
+	user := authorizedUser(q.Message.Chat, &q.From)
+	if user == nil || !user.CanEdit() {
+		b.answer(q.ID, "Permission denied")
+		return
+	}
+
+	action, monitorID, ok := notify.ParseActionData(q.Data)
+	if !ok {
+		b.answer(q.ID, "Unknown action")
+		return
+	}
+
+	var m model.Monitor
+	if err := manager.Writable(user).First(&m, monitorID).Error; err != nil {
+		b.answer(q.ID, "Monitor not found")
+		return
+	}
+
+	var err error
+	var state string
+	keyboard := notify.Keyboard([]notify.Action{{Label: "Join", URL: m.TestFlightURL}})
+	switch action {
+	case notify.ActionJoined:
+		err = manager.MarkJoined(&m)
+		state = "✅ Marked as joined, monitoring stopped"
+	case notify.ActionSnooze:
+		err = manager.Snooze(&m, time.Hour)
+		state = "🔕 Snoozed until " + m.SnoozedUntil.Format("15:04")
+		keyboard = notify.Keyboard(notify.MonitorActions(m.ID, m.TestFlightURL))
+	case notify.ActionPause:
+		err = manager.SetEnabled(&m, false)
+		state = "⏸ Monitoring paused"
+	case notify.ActionAck:
+		err = manager.Acknowledge(&m)
+		state = "👌 Acknowledged"
+		keyboard = notify.Keyboard(notify.MonitorActions(m.ID, m.TestFlightURL))
+	default:
+		b.answer(q.ID, "Unknown action")
+		return
+	}
+	if err != nil {
+		b.answer(q.ID, "Failed: "+err.Error())
+		return
+	}
+
+	b.answer(q.ID, state)
+
+	payload := map[string]interface{}{
+		"chat_id":      q.Message.Chat.ID,
+		"message_id":   q.Message.MessageID,
+		"reply_markup": keyboard,
+	}
+	// Appending keeps the offsets of the original formatting valid
+	method := "editMessageText"
+	if len(q.Message.Photo) > 0 {
+		method = "editMessageCaption"
+		payload["caption"] = q.Message.Caption + "\n\n" + state
+		if len(q.Message.CaptionEntities) > 0 {
+			payload["caption_entities"] = q.Message.CaptionEntities
+		}
+	} else {
+		payload["text"] = q.Message.Text + "\n\n" + state
+		if len(q.Message.Entities) > 0 {
+			payload["entities"] = q.Message.Entities
+		}
+	}
+	if err := b.client.Call(method, payload, nil); err != nil {
+		slog.Warn("Failed to edit alert message", logging.MonitorID(m.ID), logging.Err(err))
+	}
+}
+
+func (b *Bot) answer(callbackID, text string) {
+	payload := map[string]interface{}{
+		"callback_query_id": callbackID,
+		"text":              text,
+	}
+	if err := b.client.Call("answerCallbackQuery", payload, nil); err != nil {
+		slog.Warn("Failed to answer callback query", logging.Err(err))
+	}
+}
+
+// execute runs a command or pasted links and returns the reply text
+func (b *Bot) execute(user *model.User, text string) string {
+	if !strings.HasPrefix(text, "/") {
+		links := linkPattern.FindAllString(text, -1)
+		if len(links) == 0 {
+			return ""
+		}
+		return b.add(user, links)
+	}
+
+	fields := strings.Fields(text)
+	// Commands in groups may be addressed as /cmd@botname
+	command, _, _ := strings.Cut(fields[0], "@")
+	args := fields[1:]
+
+	switch command {
+	case "/start", "/help":
+		return helpText
+	case "/add":
+		if len(args) == 0 {
+			return "Usage: /add <url|code>..."
+		}
+		return b.add(user, args)
+	case "/list":
+		return list(user)
+	case "/pause":
+		return setEnabled(user, args, false)
+	case "/resume":
+		return setEnabled(user, args, true)
+	case "/delete":
+		return remove(user, args)
+	case "/status":
+		return status(user)
+	}
+	return "Unknown command, see /help"
+}
+
+func (b *Bot) add(user *model.User, args []string) string {
+	if !user.CanEdit() {
+		return "Permission denied"
+	}
+
+	urls := make([]string, len(args))
+	for i, arg := range args {
+		if !strings.Contains(arg, "/") {
+			arg = "https://testflight.apple.com/join/" + arg
+		}
+		urls[i] = arg
+	}
+
+	created, errs := manager.Create(user, manager.CreateParams{
+		URLs:      urls,
+		Duration:  24,
+		AutoStart: true,
+	}, b.proxyURL)
+
+	var lines []string
+	for _, m := range created {
+		lines = append(lines, fmt.Sprintf("Added #%d %s (%s)", m.ID, m.DisplayName(), m.Status))
+	}
+	for _, e := range errs {
+		lines = append(lines, "Failed: "+e)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func list(user *model.User) string {
+	var monitors []model.Monitor
+	manager.Listable(user, false).Order("created_at desc").Find(&monitors)
+	if len(monitors) == 0 {
+		return "No monitors"
+	}
+
+	lines := make([]string, len(monitors))
+	for i, m := range monitors {
+		state := "running"
+		if !m.Enabled {
+			state = "paused"
+		}
+		lines[i] = fmt.Sprintf("#%d %s - %s, %s", m.ID, m.DisplayName(), m.Status, state)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func setEnabled(user *model.User, args []string, enabled bool) string {
+	m, errText := findWritable(user, args)
+	if m == nil {
+		return errText
+	}
+
+	if err := manager.SetEnabled(m, enabled); err != nil {
+		return "Failed: " + err.Error()
+	}
+	if enabled {
+		return fmt.Sprintf("Resumed #%d %s", m.ID, m.DisplayName())
+	}
+	return fmt.Sprintf("Paused #%d %s", m.ID, m.DisplayName())
+}
+
+func remove(user *model.User, args []string) string {
+	m, errText := findWritable(user, args)
+	if m == nil {
+		return errText
+	}
+
+	if err := manager.Delete(m); err != nil {
+		return "Failed: " + err.Error()
+	}
+	return fmt.Sprintf("Deleted #%d %s", m.ID, m.DisplayName())
+}
+
+func status(user *model.User) string {
+	var count int64
+	manager.Listable(user, false).Model(&model.Monitor{}).Count(&count)
+
+	sched := scheduler.GetScheduler()
+	next := "-"
+	if t := sched.GetNextCheckTime(); !t.IsZero() {
+		next = t.Format("15:04:05")
+	}
+	return fmt.Sprintf("Monitors: %d\nActive jobs: %d\nNext check: %s", count, sched.GetActiveJobCount(), next)
+}
+
+func findWritable(user *model.User, args []string) (*model.Monitor, string) {
+	if !user.CanEdit() {
+		return nil, "Permission denied"
+	}
+	if len(args) != 1 {
+		return nil, "Usage: <command> <id>"
+	}
+
+	id, err := strconv.ParseUint(strings.TrimPrefix(args[0], "#"), 10, 32)
+	if err != nil {
+		return nil, "Invalid monitor id"
+	}
+
+	var m model.Monitor
+	if err := manager.Writable(user).First(&m, id).Error; err != nil {
+		return nil, "Monitor not found"
+	}
+	return &m, ""
+}
+
+// reply answers a message in its chat and forum topic
+func (b *Bot) reply(msg *telegram.Message, text string) {
+	if text == "" {
+		return
+	}
+	payload := map[string]interface{}{
+		"chat_id":                  msg.Chat.ID,
+		"text":                     text,
+		"disable_web_page_preview": true,
+	}
+	if msg.MessageThreadID != 0 {
+		payload["message_thread_id"] = msg.MessageThreadID
+	}
+	if err := b.client.Call("sendMessage", payload, nil); err != nil {
+		slog.Warn("Failed to send bot reply", "chat_id", msg.Chat.ID, logging.Err(err))
+	}
+}
+
+// authorizedUser returns the user whose Telegram settings target the chat.
+// Anyone in a group can write to the bot, there the sender must also be one
+// of the bot users of those settings. In a private chat the sender is the
+// chat.
+func authorizedUser(chat telegram.Chat, from *telegram.User) *model.User {
+	if from == nil {
+		return nil
+	}
+	private := chat.Type == "private" && from.ID == chat.ID
+
+	var cfgs []model.TelegramConfig
+	repository.GetDB().Where("enabled = ?", true).Order("id asc").Find(&cfgs)
+
+	id := strconv.FormatInt(chat.ID, 10)
+	for _, cfg := range cfgs {
+		for _, target := range cfg.Targets {
+			if target.ChatID != id || (!private && !slices.Contains(cfg.BotUsers, from.ID)) {
+				continue
+			}
+			var user model.User
+			if err := repository.GetDB().First(&user, cfg.UserID).Error; err != nil {
+				return nil
+			}
+			return &user
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+// Package events is the in-process bus the scheduler and the monitor
+// operations publish domain events on. History, alerts, metrics and live
+// updates subscribe to the events they need instead of being called by the
+// code that caused them.
+package events
+
+import (
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// Topic delivers events of one type to its subscribers. Handlers run
+// synchronously in the publisher's goroutine, in the order they subscribed.
+type Topic[T any] struct {
+	mu       sync.RWMutex
+	handlers []func(T) error
+}
+
+// Subscribe adds handler to the topic
+func (t *Topic[T]) Subscribe(handler func(T) error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.handlers = append(t.handlers, handler)
+}
+
+// Publish passes e to the handlers and stops at the first that fails. Events
+// are published in a transaction, the error rolls it back.
+func (t *Topic[T]) Publish(e T) error {
+	t.mu.RLock()
+	handlers := t.handlers
+	t.mu.RUnlock()
+
+	for _, handler := range handlers {
+		if err := handler(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Bus holds a topic per event type
+type Bus struct {
+	MonitorCreated   Topic[MonitorCreated]
+	MonitorDeleted   Topic[MonitorDeleted]
+	CheckCompleted   Topic[CheckCompleted]
+	StatusChanged    Topic[StatusChanged]
+	MonitorExpired   Topic[MonitorExpired]
+	NotificationSent Topic[NotificationSent]
+}
+
+var defaultBus = &Bus{}
+
+// GetBus returns the bus of the process
+func GetBus() *Bus {
+	return defaultBus
+}
+
+// Tx is the transaction an event is published in. Subscribers write through
+// it, so their changes are saved or rolled back together with the cause of the
+// event, and defer effects outside the database with AfterCommit.
+type Tx struct {
+	*gorm.DB
+	afterCommit []func()
+}
+
+// AfterCommit runs f once the transaction committed, it is dropped on rollback
+func (tx *Tx) AfterCommit(f func()) {
+	tx.afterCommit = append(tx.afterCommit, f)
+}
+
+// Transaction runs fn in a transaction of db, then the functions registered
+// with AfterCommit if it committed
+func Transaction(db *gorm.DB, fn func(tx *Tx) error) error {
+	tx := &Tx{}
+	err := db.Transaction(func(gtx *gorm.DB) error {
+		tx.DB = gtx
+		return fn(tx)
+	})
+	if err != nil {
+		return err
+	}
+	for _, f := range tx.afterCommit {
+		f()
+	}
+	return nil
+}
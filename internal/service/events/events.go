@@ -0,0 +1,60 @@
+package events
+
+import (
+	"time"
+
+	"tf-monitor/internal/model"
+)
+
+// MonitorCreated is published when a monitor was added
+type MonitorCreated struct {
+	Tx      *Tx
+	Monitor *model.Monitor
+}
+
+// MonitorDeleted is published when a monitor is deleted, Monitor holds its
+// last state
+type MonitorDeleted struct {
+	Tx      *Tx
+	Monitor *model.Monitor
+}
+
+// CheckCompleted is published when a check finished, Monitor already holds
+// the saved result
+type CheckCompleted struct {
+	Tx       *Tx
+	Monitor  *model.Monitor
+	Previous model.MonitorStatus // status before the check
+	Started  time.Time
+	Duration time.Duration
+	Err      error // why the check failed, the fields below are empty then
+
+	Available bool
+	AppName   string
+	IconURL   string
+	Message   string
+}
+
+// StatusChanged is published when a check changed the status of a monitor
+type StatusChanged struct {
+	Tx      *Tx
+	Monitor *model.Monitor
+	From    model.MonitorStatus
+	To      model.MonitorStatus
+}
+
+// MonitorExpired is published when a monitor reached the end of its duration
+// and was disabled
+type MonitorExpired struct {
+	Tx       *Tx
+	Monitor  *model.Monitor
+	Previous model.MonitorStatus // status before it expired
+}
+
+// NotificationSent is published after each delivery attempt, Err is nil when
+// the notification was delivered. Notification holds the saved outcome.
+type NotificationSent struct {
+	Tx           *Tx
+	Notification *model.Notification
+	Err          error
+}
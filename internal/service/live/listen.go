@@ -0,0 +1,92 @@
+package live
+
+import (
+	"time"
+
+	"tf-monitor/internal/model"
+	"tf-monitor/internal/service/events"
+)
+
+// monitorUpdate carries the monitor fields a check changes, named as in the
+// monitor API so clients can merge it into their copy
+type monitorUpdate struct {
+	ID             uint                `json:"id"`
+	Status         model.MonitorStatus `json:"status"`
+	PreviousStatus model.MonitorStatus `json:"previousStatus"`
+	Enabled        bool                `json:"enabled"`
+	AppName        string              `json:"appName"`
+	IconURL        string              `json:"iconUrl"`
+	LastCheck      *time.Time          `json:"lastCheck"`
+	LastError      string              `json:"lastError"`
+	AlertedAt      *time.Time          `json:"alertedAt"`
+	AckedAt        *time.Time          `json:"ackedAt"`
+	Escalated      bool                `json:"escalated"`
+}
+
+type notificationUpdate struct {
+	ID        uint                     `json:"id"`
+	MonitorID uint                     `json:"monitorId"`
+	Channel   string                   `json:"channel"`
+	Status    model.NotificationStatus `json:"status"`
+	Title     string                   `json:"title"`
+	Attempts  int                      `json:"attempts"`
+	LastError string                   `json:"lastError"`
+}
+
+// Listen streams the events of bus to clients once they are committed
+func Listen(bus *events.Bus) {
+	bus.MonitorCreated.Subscribe(func(e events.MonitorCreated) error {
+		m := e.Monitor
+		e.Tx.AfterCommit(func() {
+			Publish(MonitorCreated, m.UserID, map[string]uint{"id": m.ID})
+		})
+		return nil
+	})
+	bus.CheckCompleted.Subscribe(func(e events.CheckCompleted) error {
+		publishMonitor(e.Tx, MonitorChecked, e.Monitor, e.Previous)
+		return nil
+	})
+	bus.StatusChanged.Subscribe(func(e events.StatusChanged) error {
+		publishMonitor(e.Tx, MonitorStatusChanged, e.Monitor, e.From)
+		return nil
+	})
+	bus.MonitorExpired.Subscribe(func(e events.MonitorExpired) error {
+		publishMonitor(e.Tx, MonitorStatusChanged, e.Monitor, e.Previous)
+		return nil
+	})
+	bus.NotificationSent.Subscribe(func(e events.NotificationSent) error {
+		n := e.Notification
+		e.Tx.AfterCommit(func() {
+			Publish(NotificationUpdated, n.UserID, notificationUpdate{
+				ID:        n.ID,
+				MonitorID: n.MonitorID,
+				Channel:   n.Channel,
+				Status:    n.Status,
+				Title:     n.Title,
+				Attempts:  n.Attempts,
+				LastError: n.LastError,
+			})
+		})
+		return nil
+	})
+}
+
+// publishMonitor streams the state of m after the transaction, by then later
+// subscribers have made their changes too
+func publishMonitor(tx *events.Tx, eventType string, m *model.Monitor, prev model.MonitorStatus) {
+	tx.AfterCommit(func() {
+		Publish(eventType, m.UserID, monitorUpdate{
+			ID:             m.ID,
+			Status:         m.Status,
+			PreviousStatus: prev,
+			Enabled:        m.Enabled,
+			AppName:        m.AppName,
+			IconURL:        m.IconURL,
+			LastCheck:      m.LastCheck,
+			LastError:      m.LastError,
+			AlertedAt:      m.AlertedAt,
+			AckedAt:        m.AckedAt,
+			Escalated:      m.Escalated,
+		})
+	})
+}
@@ -0,0 +1,145 @@
+// Package live fans out scheduler events to connected UI clients. Recent
+// events are kept so a client reconnecting with the ID of the last event it
+// saw receives what it missed.
+package live
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types streamed to clients
+const (
+	MonitorCreated       = "monitor.created"
+	MonitorChecked       = "monitor.checked" // a check completed, successfully or not
+	MonitorStatusChanged = "monitor.status"  // the status changed by a check or expiry
+	NotificationUpdated  = "notification"    // a delivery attempt finished
+	Reset                = "reset"           // missed events are gone, the client has to reload
+)
+
+const (
+	historySize   = 1000 // events kept for reconnecting clients
+	subscriberBuf = 64
+)
+
+// Event is a message for the clients allowed to see the user's monitors, a
+// UserID of 0 addresses every client
+type Event struct {
+	ID     string
+	Type   string
+	UserID uint
+	Data   json.RawMessage
+}
+
+// Subscription receives events until it is closed. A subscription that falls
+// behind is closed, the client reconnects and catches up from the history.
+type Subscription struct {
+	events chan Event
+	once   sync.Once
+}
+
+// Events returns the channel events are delivered on, it is closed with the
+// subscription
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+type hub struct {
+	mu      sync.Mutex
+	boot    string // distinguishes event IDs of different processes
+	seq     uint64
+	history []Event
+	subs    map[*Subscription]struct{}
+}
+
+var defaultHub = &hub{
+	boot: strconv.FormatInt(time.Now().UnixNano(), 36),
+	subs: make(map[*Subscription]struct{}),
+}
+
+// Publish sends an event about a monitor of userID to the subscribers
+func Publish(eventType string, userID uint, data any) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		slog.Error("Failed to encode live event", "type", eventType, "error", err)
+		return
+	}
+
+	h := defaultHub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.seq++
+	e := Event{
+		ID:     h.lastID(),
+		Type:   eventType,
+		UserID: userID,
+		Data:   payload,
+	}
+	h.history = append(h.history, e)
+	if len(h.history) > historySize {
+		h.history = h.history[len(h.history)-historySize:]
+	}
+
+	for sub := range h.subs {
+		select {
+		case sub.events <- e:
+		default:
+			h.remove(sub)
+		}
+	}
+}
+
+// Subscribe starts receiving events. With the ID of the last event a client
+// saw, the events published since are returned for replay, or a Reset event
+// when they are no longer all available.
+func Subscribe(lastEventID string) (sub *Subscription, replay []Event) {
+	h := defaultHub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub = &Subscription{events: make(chan Event, subscriberBuf)}
+	h.subs[sub] = struct{}{}
+
+	if lastEventID == "" {
+		return sub, nil
+	}
+	// The reset carries the current ID, so the client does not get another one
+	// when it reconnects before the next event
+	reset := []Event{{ID: h.lastID(), Type: Reset, Data: json.RawMessage("{}")}}
+	boot, seqPart, _ := strings.Cut(lastEventID, "-")
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil || boot != h.boot || seq > h.seq {
+		return sub, reset
+	}
+	// Events are numbered without gaps, the oldest kept is seq+1 at best
+	missed := int(h.seq - seq)
+	if missed > len(h.history) {
+		return sub, reset
+	}
+	replay = append([]Event(nil), h.history[len(h.history)-missed:]...)
+	return sub, replay
+}
+
+// Unsubscribe stops delivery to sub and closes its channel
+func Unsubscribe(sub *Subscription) {
+	h := defaultHub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(sub)
+}
+
+// remove drops sub, the caller holds h.mu
+func (h *hub) remove(sub *Subscription) {
+	delete(h.subs, sub)
+	sub.once.Do(func() { close(sub.events) })
+}
+
+func (h *hub) lastID() string {
+	return fmt.Sprintf("%s-%d", h.boot, h.seq)
+}
@@ -0,0 +1,225 @@
+package manager
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"tf-monitor/internal/model"
+
+	"github.com/goccy/go-yaml"
+)
+
+// File formats of exports and imports
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+	FormatYAML = "yaml"
+)
+
+// csvColumns are the columns of CSV files. Imports match them by name in the
+// header row, in any order, and ignore others.
+// The policy_ columns hold the fields of the notification policy.
+var csvColumns = []string{
+	"url", "appName", "interval", "duration", "notifyMode", "enabled", "escalateAfter", "routes",
+	"policy_minRepeatInterval", "policy_maxPerHour", "policy_quietHours", "policy_quietMode", "policy_timezone",
+}
+
+// csvRouteSeparator joins the routes of a monitor in a CSV cell
+const csvRouteSeparator = ";"
+
+// ContentType returns the media type of a format, empty for unknown formats
+func ContentType(format string) string {
+	switch format {
+	case FormatJSON:
+		return "application/json"
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatYAML:
+		return "application/yaml"
+	}
+	return ""
+}
+
+// EncodeEntries writes entries in format
+func EncodeEntries(w io.Writer, format string, entries []Entry) error {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(entries)
+	case FormatYAML:
+		data, err := yaml.Marshal(entries)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	case FormatCSV:
+		return encodeCSV(w, entries)
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// DecodeEntries reads entries in format
+func DecodeEntries(r io.Reader, format string) ([]Entry, error) {
+	var entries []Entry
+	switch format {
+	case FormatJSON:
+		if err := json.NewDecoder(r).Decode(&entries); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+	case FormatYAML:
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+	case FormatCSV:
+		return decodeCSV(r)
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	return entries, nil
+}
+
+func encodeCSV(w io.Writer, entries []Entry) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvColumns); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		enabled := ""
+		if e.Enabled != nil {
+			enabled = strconv.FormatBool(*e.Enabled)
+		}
+		policy := []string{"", "", "", "", ""}
+		if p := e.Policy; p != nil {
+			policy = []string{
+				strconv.Itoa(p.MinRepeatInterval),
+				strconv.Itoa(p.MaxPerHour),
+				p.QuietHours,
+				string(p.QuietMode),
+				p.Timezone,
+			}
+		}
+		if err := cw.Write(append([]string{
+			e.URL,
+			e.AppName,
+			strconv.Itoa(e.Interval),
+			strconv.Itoa(e.Duration),
+			string(e.NotifyMode),
+			enabled,
+			strconv.Itoa(e.EscalateAfter),
+			strings.Join(e.Routes, csvRouteSeparator),
+		}, policy...)); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func decodeCSV(r io.Reader) ([]Entry, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		for _, known := range csvColumns {
+			if strings.EqualFold(strings.TrimSpace(name), known) {
+				columns[known] = i
+			}
+		}
+	}
+	if _, ok := columns["url"]; !ok {
+		return nil, fmt.Errorf("CSV header has no url column")
+	}
+
+	var entries []Entry
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+
+		cell := func(column string) string {
+			i, ok := columns[column]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		number := func(column string) (int, error) {
+			value := cell(column)
+			if value == "" {
+				return 0, nil
+			}
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return 0, fmt.Errorf("line %d: %s must be a number", line, column)
+			}
+			return n, nil
+		}
+
+		e := Entry{
+			URL:        cell("url"),
+			AppName:    cell("appName"),
+			NotifyMode: model.NotifyMode(cell("notifyMode")),
+		}
+		if e.Interval, err = number("interval"); err != nil {
+			return nil, err
+		}
+		if e.Duration, err = number("duration"); err != nil {
+			return nil, err
+		}
+		if e.EscalateAfter, err = number("escalateAfter"); err != nil {
+			return nil, err
+		}
+		if value := cell("enabled"); value != "" {
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: enabled must be true or false", line)
+			}
+			e.Enabled = &enabled
+		}
+		if value := cell("routes"); value != "" {
+			e.Routes = strings.Split(value, csvRouteSeparator)
+		}
+
+		// Left out when all cells are empty, like in JSON and YAML
+		var policy model.NotifyPolicy
+		if policy.MinRepeatInterval, err = number("policy_minRepeatInterval"); err != nil {
+			return nil, err
+		}
+		if policy.MaxPerHour, err = number("policy_maxPerHour"); err != nil {
+			return nil, err
+		}
+		policy.QuietHours = cell("policy_quietHours")
+		policy.QuietMode = model.QuietMode(cell("policy_quietMode"))
+		policy.Timezone = cell("policy_timezone")
+		if policy != (model.NotifyPolicy{}) {
+			e.Policy = &policy
+		}
+		entries = append(entries, e)
+	}
+}
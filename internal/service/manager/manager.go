@@ -0,0 +1,302 @@
+// Package manager holds monitor operations shared by the HTTP API and the
+// Telegram bot, so both apply the same validation, scoping and scheduling.
+package manager
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"tf-monitor/internal/model"
+	"tf-monitor/internal/repository"
+	"tf-monitor/internal/service/events"
+	"tf-monitor/internal/service/monitor"
+	"tf-monitor/internal/service/scheduler"
+
+	"gorm.io/gorm"
+)
+
+// CreateParams describes monitors to be created from a list of URLs
+type CreateParams struct {
+	URLs          []string
+	Interval      int
+	Duration      int
+	NotifyMode    model.NotifyMode
+	Policy        model.NotifyPolicy
+	EscalateAfter int      // minutes an alert may stay unacknowledged
+	Routes        []string // checked with ValidateRoutes
+	AutoStart     bool
+}
+
+// Readable limits monitor queries to those the user may see
+func Readable(user *model.User) *gorm.DB {
+	if user.CanViewAll() {
+		return repository.GetDB()
+	}
+	return repository.GetDB().Where("user_id = ?", user.ID)
+}
+
+// Writable limits monitor queries to those the user may change
+func Writable(user *model.User) *gorm.DB {
+	if user.IsAdmin() {
+		return repository.GetDB()
+	}
+	return repository.GetDB().Where("user_id = ?", user.ID)
+}
+
+// Listable returns the monitors shown in a user's list. Editors and admins see
+// their own monitors, admins may ask for all. Viewers always see all, they
+// cannot own monitors and are given read-only access to the team's.
+func Listable(user *model.User, all bool) *gorm.DB {
+	if user.Role == model.RoleEditor || (user.IsAdmin() && !all) {
+		return repository.GetDB().Where("user_id = ?", user.ID)
+	}
+	return repository.GetDB()
+}
+
+// Create adds a monitor per URL for the user. URLs that are invalid or
+// already monitored by the user are reported in errs.
+func Create(user *model.User, params CreateParams, proxyURL string) (created []model.Monitor, errs []string) {
+	checker := monitor.NewChecker(proxyURL)
+
+	for _, url := range params.URLs {
+		url = strings.TrimSpace(url)
+		if url == "" {
+			continue
+		}
+
+		appID, err := monitor.ParseURL(url)
+		if err != nil {
+			errs = append(errs, url+": "+err.Error())
+			continue
+		}
+
+		var existing model.Monitor
+		if repository.GetDB().Where("user_id = ? AND app_id = ?", user.ID, appID).First(&existing).Error == nil {
+			errs = append(errs, url+": already exists")
+			continue
+		}
+
+		interval := params.Interval
+		if interval < 10 {
+			interval = 30
+		}
+
+		notifyMode := params.NotifyMode
+		if notifyMode == "" {
+			notifyMode = model.NotifyOnce
+		}
+
+		m := model.Monitor{
+			UserID:        user.ID,
+			AppID:         appID,
+			TestFlightURL: url,
+			Interval:      interval,
+			Duration:      params.Duration,
+			NotifyMode:    notifyMode,
+			Policy:        params.Policy,
+			EscalateAfter: params.EscalateAfter,
+			Routes:        params.Routes,
+			Enabled:       params.AutoStart,
+			ExpireAt:      expireAt(params.Duration),
+		}
+
+		info, err := checker.Check(appID)
+		if err == nil {
+			m.AppName = info.AppName
+			m.IconURL = info.IconURL
+			if info.Available {
+				m.Status = model.StatusAvailable
+			} else {
+				m.Status = model.StatusFull
+			}
+		}
+
+		// Subscribers start checking the monitor if it is enabled
+		err = events.Transaction(repository.GetDB(), func(tx *events.Tx) error {
+			if err := purgeDeleted(tx, m.UserID, m.TestFlightURL); err != nil {
+				return err
+			}
+			if err := tx.Create(&m).Error; err != nil {
+				return err
+			}
+			// false is left to the column default by Create
+			if !params.AutoStart {
+				if err := tx.Model(&m).Update("enabled", false).Error; err != nil {
+					return err
+				}
+			}
+			return events.GetBus().MonitorCreated.Publish(events.MonitorCreated{Tx: tx, Monitor: &m})
+		})
+		if err != nil {
+			errs = append(errs, url+": "+err.Error())
+			continue
+		}
+
+		created = append(created, m)
+	}
+
+	return created, errs
+}
+
+// SetEnabled starts or stops monitoring. Starting restarts the monitor
+// duration and re-arms once-mode notifications and acknowledgement.
+func SetEnabled(m *model.Monitor, enabled bool) error {
+	m.Enabled = enabled
+	if enabled {
+		m.ExpireAt = expireAt(m.Duration)
+		m.Notified = false
+		m.AlertedAt = nil
+		m.AckedAt = nil
+		m.Escalated = false
+	}
+	if err := repository.GetDB().Save(m).Error; err != nil {
+		return err
+	}
+
+	if enabled {
+		scheduler.GetScheduler().StartJob(m.ID)
+	} else {
+		scheduler.GetScheduler().StopJob(m.ID)
+	}
+	return nil
+}
+
+// MarkJoined records that the beta was joined and stops monitoring it
+func MarkJoined(m *model.Monitor) error {
+	scheduler.GetScheduler().StopJob(m.ID)
+	m.Enabled = false
+	m.Status = model.StatusJoined
+	return repository.GetDB().Model(m).Updates(map[string]interface{}{
+		"enabled": false,
+		"status":  model.StatusJoined,
+	}).Error
+}
+
+// Snooze mutes notifications of a monitor for d, checks keep running
+func Snooze(m *model.Monitor, d time.Duration) error {
+	until := time.Now().Add(d)
+	m.SnoozedUntil = &until
+	return repository.GetDB().Model(m).Update("snoozed_until", until).Error
+}
+
+// Acknowledge marks the current alert of a monitor as seen. It is no longer
+// repeated in loop mode or escalated, and queued repeats are dropped.
+func Acknowledge(m *model.Monitor) error {
+	if m.AckedAt != nil {
+		return nil
+	}
+	now := time.Now()
+	return repository.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("monitor_id = ? AND status = ?", m.ID, model.NotificationPending).
+			Delete(&model.Notification{}).Error; err != nil {
+			return err
+		}
+		m.AckedAt = &now
+		return tx.Model(m).Update("acked_at", now).Error
+	})
+}
+
+// ValidateRoutes normalizes the routes of a monitor owned by userID. Each
+// must name a group of the owner's Telegram targets.
+func ValidateRoutes(userID uint, routes []string) ([]string, error) {
+	routes, err := model.NormalizeRoutes(routes)
+	if err != nil || len(routes) == 0 {
+		return routes, err
+	}
+
+	var cfg model.TelegramConfig
+	repository.GetDB().Where("user_id = ?", userID).First(&cfg)
+	for _, route := range routes {
+		if !cfg.HasRoute(route) {
+			return nil, fmt.Errorf("no Telegram chat belongs to route %q", route)
+		}
+	}
+	return routes, nil
+}
+
+// UpdateParams are the settings to change on a monitor, nil fields are kept.
+// Policy must pass Validate and Routes ValidateRoutes beforehand.
+type UpdateParams struct {
+	Interval      *int
+	Duration      *int // restarts the duration
+	NotifyMode    *model.NotifyMode
+	Policy        *model.NotifyPolicy
+	EscalateAfter *int
+	Routes        *[]string // empty selects the default route
+}
+
+// Update writes the changed settings of a monitor in one transaction, so a
+// failure leaves none of them applied
+func Update(m *model.Monitor, params UpdateParams) error {
+	updates := make(map[string]interface{})
+	if params.Interval != nil {
+		updates["interval"] = *params.Interval
+	}
+	if params.Duration != nil {
+		updates["duration"] = *params.Duration
+		updates["expire_at"] = expireAt(*params.Duration)
+	}
+	if params.NotifyMode != nil {
+		updates["notify_mode"] = *params.NotifyMode
+	}
+	if params.Policy != nil {
+		updates["policy_min_repeat_interval"] = params.Policy.MinRepeatInterval
+		updates["policy_max_per_hour"] = params.Policy.MaxPerHour
+		updates["policy_quiet_hours"] = params.Policy.QuietHours
+		updates["policy_quiet_mode"] = params.Policy.QuietMode
+		updates["policy_timezone"] = params.Policy.Timezone
+	}
+	if params.EscalateAfter != nil {
+		updates["escalate_after"] = *params.EscalateAfter
+	}
+
+	return events.Transaction(repository.GetDB(), func(tx *events.Tx) error {
+		if len(updates) > 0 {
+			if err := tx.Model(m).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if params.Routes != nil {
+			m.Routes = *params.Routes
+			if err := tx.Model(m).Select("routes").Updates(m).Error; err != nil {
+				return err
+			}
+		}
+		return tx.First(m, m.ID).Error
+	})
+}
+
+// Delete stops and removes a monitor along with its undelivered notifications
+func Delete(m *model.Monitor) error {
+	scheduler.GetScheduler().StopJob(m.ID)
+	return events.Transaction(repository.GetDB(), func(tx *events.Tx) error {
+		if err := tx.Where("monitor_id = ? AND status = ?", m.ID, model.NotificationPending).
+			Delete(&model.Notification{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(m).Error; err != nil {
+			return err
+		}
+		return events.GetBus().MonitorDeleted.Publish(events.MonitorDeleted{Tx: tx, Monitor: m})
+	})
+}
+
+// purgeDeleted removes a deleted monitor of the user at url for good. Deleted
+// monitors keep their row, and with it the link they would share with a new
+// one.
+func purgeDeleted(tx *events.Tx, userID uint, url string) error {
+	return tx.Unscoped().
+		Where("user_id = ? AND test_flight_url = ? AND deleted_at IS NOT NULL", userID, url).
+		Delete(&model.Monitor{}).Error
+}
+
+// expireAt returns when a monitor started now with the given duration in
+// hours expires, nil for monitors that run forever
+func expireAt(duration int) *time.Time {
+	if duration <= 0 {
+		return nil
+	}
+	t := time.Now().Add(time.Duration(duration) * time.Hour)
+	return &t
+}
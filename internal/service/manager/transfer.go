@@ -0,0 +1,300 @@
+package manager
+
+import (
+	"fmt"
+	"strings"
+
+	"tf-monitor/internal/model"
+	"tf-monitor/internal/repository"
+	"tf-monitor/internal/service/events"
+	"tf-monitor/internal/service/monitor"
+	"tf-monitor/internal/service/scheduler"
+)
+
+// Entry is a monitor in an export file. Only the settings are carried over,
+// the state of the checks and alerts stays behind.
+type Entry struct {
+	URL           string              `json:"url" yaml:"url"`
+	AppName       string              `json:"appName,omitempty" yaml:"appName,omitempty"`
+	Interval      int                 `json:"interval,omitempty" yaml:"interval,omitempty"` // seconds
+	Duration      int                 `json:"duration" yaml:"duration"`                     // hours, 0 runs until paused
+	NotifyMode    model.NotifyMode    `json:"notifyMode,omitempty" yaml:"notifyMode,omitempty"`
+	Enabled       *bool               `json:"enabled,omitempty" yaml:"enabled,omitempty"` // true when left out
+	Policy        *model.NotifyPolicy `json:"policy,omitempty" yaml:"policy,omitempty"`   // global policy when left out
+	EscalateAfter int                 `json:"escalateAfter,omitempty" yaml:"escalateAfter,omitempty"`
+	Routes        []string            `json:"routes,omitempty" yaml:"routes,omitempty"`
+}
+
+// Conflict strategies for imported monitors of apps the user already monitors
+const (
+	ConflictSkip      = "skip"      // keep the existing monitor
+	ConflictOverwrite = "overwrite" // apply the imported settings to it
+	ConflictRename    = "rename"    // give it the imported name, keeping its settings
+)
+
+// Actions of an import, per entry
+const (
+	ImportCreate = "create"
+	ImportUpdate = "update"
+	ImportSkip   = "skip"
+	ImportRename = "rename"
+	ImportError  = "error"
+)
+
+// ImportResult reports what an import did, or would do in a dry run, with
+// an entry
+type ImportResult struct {
+	Index     int    `json:"index"` // position in the file, from 1
+	URL       string `json:"url"`
+	AppID     string `json:"appId"`
+	Action    string `json:"action"`
+	MonitorID uint   `json:"monitorId,omitempty"` // of the monitor updated or renamed, or created outside a dry run
+	AppName   string `json:"appName,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Export returns the entries of monitors
+func Export(monitors []model.Monitor) []Entry {
+	entries := make([]Entry, len(monitors))
+	for i := range monitors {
+		m := &monitors[i]
+		enabled := m.Enabled
+		var policy *model.NotifyPolicy
+		if m.Policy != (model.NotifyPolicy{}) {
+			policy = &m.Policy
+		}
+		entries[i] = Entry{
+			URL:           m.TestFlightURL,
+			AppName:       m.AppName,
+			Interval:      m.Interval,
+			Duration:      m.Duration,
+			NotifyMode:    m.NotifyMode,
+			Enabled:       &enabled,
+			Policy:        policy,
+			EscalateAfter: m.EscalateAfter,
+			Routes:        m.Routes,
+		}
+	}
+	return entries
+}
+
+// ValidConflict reports whether strategy is a known conflict strategy
+func ValidConflict(strategy string) bool {
+	return strategy == ConflictSkip || strategy == ConflictOverwrite || strategy == ConflictRename
+}
+
+// Import adds the entries as monitors of the user. Entries for apps the user
+// already monitors, including earlier entries of the same file, are handled
+// by the conflict strategy, as a user has one monitor per app. Invalid
+// entries are reported and left out, the others are written in one
+// transaction unless dryRun is set. Unlike Create it does not fetch the apps
+// first, the first scheduled check does.
+func Import(user *model.User, entries []Entry, conflict string, dryRun bool) ([]ImportResult, error) {
+	var owned []model.Monitor
+	if err := repository.GetDB().Where("user_id = ?", user.ID).Order("id asc").Find(&owned).Error; err != nil {
+		return nil, err
+	}
+	byApp := make(map[string]*model.Monitor, len(owned))
+	for i := range owned {
+		byApp[owned[i].AppID] = &owned[i]
+	}
+
+	// Decided up front, so a dry run reports exactly what an import does
+	results := make([]ImportResult, len(entries))
+	planned := make([]*model.Monitor, len(entries))
+	targets := make([]*model.Monitor, len(entries))
+	for i, e := range entries {
+		r := &results[i]
+		r.Index = i + 1
+		r.URL = e.URL
+
+		m, err := importMonitor(user.ID, e)
+		if err != nil {
+			r.Action = ImportError
+			r.Error = err.Error()
+			continue
+		}
+		r.AppID = m.AppID
+
+		existing := byApp[m.AppID]
+		switch {
+		case existing == nil:
+			r.Action = ImportCreate
+			r.AppName = m.AppName
+			planned[i] = m
+			byApp[m.AppID] = m
+			continue
+		case conflict == ConflictOverwrite:
+			r.Action = ImportUpdate
+		case conflict == ConflictRename && m.AppName != "" && m.AppName != existing.AppName:
+			r.Action = ImportRename
+		default:
+			r.Action = ImportSkip
+		}
+		// Monitors created by earlier entries get their ID in the transaction
+		r.MonitorID = existing.ID
+		r.AppName = existing.AppName
+		targets[i] = existing
+		if r.Action != ImportSkip {
+			planned[i] = m
+		}
+		if r.Action == ImportRename {
+			// Later entries of the app see the new name
+			existing.AppName = m.AppName
+			r.AppName = m.AppName
+		}
+	}
+	if dryRun {
+		return results, nil
+	}
+
+	err := events.Transaction(repository.GetDB(), func(tx *events.Tx) error {
+		for i, m := range planned {
+			var err error
+			switch results[i].Action {
+			case ImportCreate:
+				err = createImported(tx, m)
+				targets[i] = m
+			case ImportUpdate:
+				err = overwrite(tx, targets[i], m)
+			case ImportRename:
+				err = tx.Model(targets[i]).Update("app_name", m.AppName).Error
+			}
+			if err != nil {
+				return err
+			}
+			if targets[i] != nil {
+				results[i].MonitorID = targets[i].ID
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// importMonitor validates an entry and returns the monitor it describes
+func importMonitor(userID uint, e Entry) (*model.Monitor, error) {
+	url := strings.TrimSpace(e.URL)
+	if url != "" && !strings.Contains(url, "/") {
+		url = "https://testflight.apple.com/join/" + url
+	}
+	appID, err := monitor.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := e.Interval
+	if interval == 0 {
+		interval = 30
+	}
+	if interval < 10 {
+		return nil, fmt.Errorf("interval must be at least 10 seconds")
+	}
+	if e.Duration < 0 {
+		return nil, fmt.Errorf("duration must not be negative")
+	}
+	if e.EscalateAfter < 0 {
+		return nil, fmt.Errorf("escalateAfter must not be negative")
+	}
+	notifyMode := e.NotifyMode
+	switch notifyMode {
+	case "":
+		notifyMode = model.NotifyOnce
+	case model.NotifyOnce, model.NotifyLoop, model.NotifyOnlyAvailable:
+	default:
+		return nil, fmt.Errorf("unknown notifyMode %q", notifyMode)
+	}
+	var policy model.NotifyPolicy
+	if e.Policy != nil {
+		if err := e.Policy.Validate(); err != nil {
+			return nil, err
+		}
+		policy = *e.Policy
+	}
+	routes, err := ValidateRoutes(userID, e.Routes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.Monitor{
+		UserID:        userID,
+		AppID:         appID,
+		AppName:       strings.TrimSpace(e.AppName),
+		TestFlightURL: url,
+		Interval:      interval,
+		Duration:      e.Duration,
+		NotifyMode:    notifyMode,
+		Policy:        policy,
+		EscalateAfter: e.EscalateAfter,
+		Routes:        routes,
+		Enabled:       e.Enabled == nil || *e.Enabled,
+	}, nil
+}
+
+// createImported adds m, subscribers start checking it if it is enabled
+func createImported(tx *events.Tx, m *model.Monitor) error {
+	if err := purgeDeleted(tx, m.UserID, m.TestFlightURL); err != nil {
+		return err
+	}
+
+	enabled := m.Enabled
+	m.ExpireAt = expireAt(m.Duration)
+	if err := tx.Create(m).Error; err != nil {
+		return err
+	}
+	// false is left to the column default by Create
+	if !enabled {
+		if err := tx.Model(m).Update("enabled", false).Error; err != nil {
+			return err
+		}
+	}
+	return events.GetBus().MonitorCreated.Publish(events.MonitorCreated{Tx: tx, Monitor: m})
+}
+
+// overwrite applies the settings of imported to m. Like SetEnabled, starting
+// the monitor restarts its duration and re-arms its alerts.
+func overwrite(tx *events.Tx, m, imported *model.Monitor) error {
+	updates := map[string]interface{}{
+		"interval":       imported.Interval,
+		"duration":       imported.Duration,
+		"notify_mode":    imported.NotifyMode,
+		"escalate_after": imported.EscalateAfter,
+		"enabled":        imported.Enabled,
+		"expire_at":      expireAt(imported.Duration),
+
+		"policy_min_repeat_interval": imported.Policy.MinRepeatInterval,
+		"policy_max_per_hour":        imported.Policy.MaxPerHour,
+		"policy_quiet_hours":         imported.Policy.QuietHours,
+		"policy_quiet_mode":          imported.Policy.QuietMode,
+		"policy_timezone":            imported.Policy.Timezone,
+	}
+	if imported.AppName != "" {
+		updates["app_name"] = imported.AppName
+	}
+	started := imported.Enabled && !m.Enabled
+	if started {
+		updates["notified"] = false
+		updates["alerted_at"] = nil
+		updates["acked_at"] = nil
+		updates["escalated"] = false
+	}
+	if err := tx.Model(m).Updates(updates).Error; err != nil {
+		return err
+	}
+	m.Routes = imported.Routes
+	if err := tx.Model(m).Select("routes").Updates(m).Error; err != nil {
+		return err
+	}
+
+	id := m.ID
+	switch {
+	case started:
+		tx.AfterCommit(func() { scheduler.GetScheduler().StartJob(id) })
+	case !imported.Enabled:
+		tx.AfterCommit(func() { scheduler.GetScheduler().StopJob(id) })
+	}
+	return nil
+}
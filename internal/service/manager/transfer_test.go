@@ -0,0 +1,266 @@
+package manager
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"tf-monitor/internal/model"
+	"tf-monitor/internal/repository"
+)
+
+const (
+	appA = "https://testflight.apple.com/join/aaaa1111"
+	appB = "https://testflight.apple.com/join/bbbb2222"
+)
+
+// setupDB opens a new database with a user monitoring appA as "App A", and
+// returns the user
+func setupDB(t *testing.T) *model.User {
+	t.Helper()
+	if err := repository.InitDB(filepath.Join(t.TempDir(), "tf-monitor.db")); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() {
+		if db, err := repository.GetDB().DB(); err == nil {
+			db.Close()
+		}
+	})
+
+	db := repository.GetDB()
+	user := &model.User{Username: "alice", Role: model.RoleEditor}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&model.TelegramConfig{
+		UserID:  user.ID,
+		Targets: []model.TelegramTarget{{ChatID: "1"}, {ChatID: "2", Group: "ios"}},
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&model.Monitor{
+		UserID: user.ID, AppID: "aaaa1111", AppName: "App A", TestFlightURL: appA,
+		Interval: 60, NotifyMode: model.NotifyLoop, Enabled: true,
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// monitors returns the user's monitors by app ID
+func monitors(t *testing.T, user *model.User) map[string]model.Monitor {
+	t.Helper()
+	var list []model.Monitor
+	if err := repository.GetDB().Where("user_id = ?", user.ID).Find(&list).Error; err != nil {
+		t.Fatal(err)
+	}
+	byApp := make(map[string]model.Monitor, len(list))
+	for _, m := range list {
+		if _, dup := byApp[m.AppID]; dup {
+			t.Errorf("two monitors of %s", m.AppID)
+		}
+		byApp[m.AppID] = m
+	}
+	return byApp
+}
+
+func actions(results []ImportResult) []string {
+	list := make([]string, len(results))
+	for i, r := range results {
+		list[i] = r.Action
+	}
+	return list
+}
+
+func TestImportConflicts(t *testing.T) {
+	policy := &model.NotifyPolicy{MaxPerHour: 2, QuietHours: "22:00-07:00"}
+	entries := []Entry{
+		{URL: appA, AppName: "Renamed A", Interval: 120, NotifyMode: model.NotifyOnce, Policy: policy, Routes: []string{"ios"}},
+		{URL: "bbbb2222", AppName: "App B"},
+		{URL: appB, AppName: "Second B", Interval: 300}, // same app as the entry before
+	}
+
+	tests := []struct {
+		conflict    string
+		wantActions []string
+		check       func(t *testing.T, a, b model.Monitor)
+	}{
+		{ConflictSkip, []string{ImportSkip, ImportCreate, ImportSkip}, func(t *testing.T, a, b model.Monitor) {
+			if a.AppName != "App A" || a.Interval != 60 || a.NotifyMode != model.NotifyLoop || a.Policy != (model.NotifyPolicy{}) {
+				t.Errorf("skipped monitor changed: %+v", a)
+			}
+			if b.AppName != "App B" || b.Interval != 30 {
+				t.Errorf("created monitor = %+v", b)
+			}
+		}},
+		{ConflictOverwrite, []string{ImportUpdate, ImportCreate, ImportUpdate}, func(t *testing.T, a, b model.Monitor) {
+			if a.AppName != "Renamed A" || a.Interval != 120 || a.NotifyMode != model.NotifyOnce ||
+				a.Policy != *policy || !reflect.DeepEqual(a.Routes, []string{"ios"}) {
+				t.Errorf("overwritten monitor = %+v", a)
+			}
+			if b.AppName != "Second B" || b.Interval != 300 {
+				t.Errorf("monitor overwritten by a later entry = %+v", b)
+			}
+		}},
+		{ConflictRename, []string{ImportRename, ImportCreate, ImportRename}, func(t *testing.T, a, b model.Monitor) {
+			if a.AppName != "Renamed A" || a.Interval != 60 || a.NotifyMode != model.NotifyLoop || a.Policy != (model.NotifyPolicy{}) {
+				t.Errorf("renamed monitor = %+v, want only the name changed", a)
+			}
+			if b.AppName != "Second B" || b.Interval != 30 {
+				t.Errorf("monitor renamed by a later entry = %+v", b)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.conflict, func(t *testing.T) {
+			user := setupDB(t)
+
+			dry, err := Import(user, entries, tt.conflict, true)
+			if err != nil {
+				t.Fatalf("dry run: %v", err)
+			}
+			if got := monitors(t, user); len(got) != 1 || got["aaaa1111"].AppName != "App A" {
+				t.Fatalf("dry run changed the monitors: %+v", got)
+			}
+
+			results, err := Import(user, entries, tt.conflict, false)
+			if err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+			if got := actions(results); !reflect.DeepEqual(got, tt.wantActions) {
+				t.Errorf("actions = %v, want %v", got, tt.wantActions)
+			}
+			if got := actions(dry); !reflect.DeepEqual(got, actions(results)) {
+				t.Errorf("dry run actions = %v, import did %v", got, actions(results))
+			}
+
+			byApp := monitors(t, user)
+			if len(byApp) != 2 {
+				t.Fatalf("monitors = %+v, want one per app", byApp)
+			}
+			for _, r := range results {
+				if r.MonitorID != byApp[r.AppID].ID {
+					t.Errorf("entry %d reports monitor %d, want %d", r.Index, r.MonitorID, byApp[r.AppID].ID)
+				}
+			}
+			tt.check(t, byApp["aaaa1111"], byApp["bbbb2222"])
+		})
+	}
+}
+
+func TestImportRenameWithoutName(t *testing.T) {
+	user := setupDB(t)
+	results, err := Import(user, []Entry{{URL: appA}, {URL: appA, AppName: "App A"}}, ConflictRename, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := actions(results); !reflect.DeepEqual(got, []string{ImportSkip, ImportSkip}) {
+		t.Errorf("actions = %v, want entries without a new name skipped", got)
+	}
+}
+
+func TestImportInvalidEntries(t *testing.T) {
+	user := setupDB(t)
+	disabled := false
+	entries := []Entry{
+		{URL: "https://example.com/not-testflight"},
+		{URL: appB, Interval: 5},
+		{URL: appB, Duration: -1},
+		{URL: appB, NotifyMode: "sometimes"},
+		{URL: appB, EscalateAfter: -1},
+		{URL: appB, Policy: &model.NotifyPolicy{QuietMode: "later"}},
+		{URL: appB, Routes: []string{"android"}},
+		{URL: appB, Enabled: &disabled, Routes: []string{"ios"}},
+	}
+	results, err := Import(user, entries, ConflictSkip, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results[:len(results)-1] {
+		if r.Action != ImportError || r.Error == "" {
+			t.Errorf("entry %d = %+v, want an error", r.Index, r)
+		}
+	}
+	if last := results[len(results)-1]; last.Action != ImportCreate {
+		t.Errorf("valid entry = %+v", last)
+	}
+	if b := monitors(t, user)["bbbb2222"]; b.Enabled || !reflect.DeepEqual(b.Routes, []string{"ios"}) {
+		t.Errorf("imported monitor = %+v", b)
+	}
+}
+
+func TestImportAfterDelete(t *testing.T) {
+	user := setupDB(t)
+	var a model.Monitor
+	repository.GetDB().Where("app_id = ?", "aaaa1111").First(&a)
+	if err := repository.GetDB().Delete(&a).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// The deleted monitor's row must not block the link
+	results, err := Import(user, []Entry{{URL: appA}}, ConflictSkip, false)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if results[0].Action != ImportCreate {
+		t.Errorf("result = %+v", results[0])
+	}
+}
+
+func TestEntriesRoundTrip(t *testing.T) {
+	enabled, disabled := true, false
+	entries := []Entry{
+		{URL: appA, AppName: "App, \"A\"", Interval: 60, Duration: 24, NotifyMode: model.NotifyLoop, Enabled: &enabled,
+			Policy:        &model.NotifyPolicy{MinRepeatInterval: 300, MaxPerHour: 2, QuietHours: "22:00-07:00", QuietMode: model.QuietDrop, Timezone: "Asia/Shanghai"},
+			EscalateAfter: 15, Routes: []string{"ios", "team"}},
+		{URL: appB, Interval: 30, NotifyMode: model.NotifyOnce, Enabled: &disabled},
+	}
+
+	for _, format := range []string{FormatJSON, FormatYAML, FormatCSV} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := EncodeEntries(&buf, format, entries); err != nil {
+				t.Fatalf("EncodeEntries: %v", err)
+			}
+			decoded, err := DecodeEntries(&buf, format)
+			if err != nil {
+				t.Fatalf("DecodeEntries: %v\n%s", err, buf.String())
+			}
+			if !reflect.DeepEqual(decoded, entries) {
+				t.Errorf("round trip =\n%+v\nwant\n%+v", decoded, entries)
+			}
+		})
+	}
+}
+
+func TestDecodeCSV(t *testing.T) {
+	// Columns in any order and case, unknown ones ignored
+	csv := "Routes,URL,comment,enabled,policy_quietHours\n" +
+		"ios;team, aaaa1111 ,hello,false,\n" +
+		",bbbb2222,,,23:00-06:00\n"
+	entries, err := DecodeEntries(bytes.NewBufferString(csv), FormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	disabled := false
+	want := []Entry{
+		{URL: "aaaa1111", Enabled: &disabled, Routes: []string{"ios", "team"}},
+		{URL: "bbbb2222", Policy: &model.NotifyPolicy{QuietHours: "23:00-06:00"}},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("DecodeEntries = %+v, want %+v", entries, want)
+	}
+
+	for name, bad := range map[string]string{
+		"no url column":  "name\nx\n",
+		"bad number":     "url,interval\naaaa1111,often\n",
+		"bad enabled":    "url,enabled\naaaa1111,maybe\n",
+		"bad policy":     "url,policy_maxPerHour\naaaa1111,many\n",
+		"unclosed quote": "url\n\"aaaa1111\n",
+	} {
+		if _, err := DecodeEntries(bytes.NewBufferString(bad), FormatCSV); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
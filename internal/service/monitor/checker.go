@@ -5,9 +5,12 @@ import (
 	"net/http"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"tf-monitor/internal/metrics"
+
 	"github.com/PuerkitoBio/goquery"
 )
 
@@ -76,9 +79,11 @@ func (c *Checker) Check(appID string) (*TestFlightInfo, error) {
 
 	resp, err := c.client.Do(req)
 	if err != nil {
+		metrics.AppleResponses.Inc("error")
 		return nil, err
 	}
 	defer resp.Body.Close()
+	metrics.AppleResponses.Inc(strconv.Itoa(resp.StatusCode))
 
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
@@ -0,0 +1,47 @@
+package notify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AckToken signs a link that acknowledges the alert of a monitor raised at
+// alertedAt. It stops working once a later alert is raised.
+func AckToken(key []byte, monitorID uint, alertedAt time.Time) string {
+	payload := fmt.Sprintf("%d.%d", monitorID, alertedAt.Unix())
+	return payload + "." + ackSignature(key, payload)
+}
+
+// ParseAckToken verifies a token produced by AckToken
+func ParseAckToken(key []byte, token string) (monitorID uint, alertedAt int64, ok bool) {
+	i := strings.LastIndex(token, ".")
+	if i < 0 {
+		return 0, 0, false
+	}
+	payload, sig := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(ackSignature(key, payload))) {
+		return 0, 0, false
+	}
+
+	idPart, tsPart, _ := strings.Cut(payload, ".")
+	id, err := strconv.ParseUint(idPart, 10, 32)
+	if err != nil {
+		return 0, 0, false
+	}
+	ts, err := strconv.ParseInt(tsPart, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return uint(id), ts, true
+}
+
+func ackSignature(key []byte, payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("ack:" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,45 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAckToken(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	alertedAt := time.Unix(1700000000, 0)
+	token := AckToken(key, 42, alertedAt)
+
+	id, ts, ok := ParseAckToken(key, token)
+	if !ok || id != 42 || ts != alertedAt.Unix() {
+		t.Fatalf("ParseAckToken(%q) = %d, %d, %v", token, id, ts, ok)
+	}
+
+	sig := token[strings.LastIndex(token, ".")+1:]
+	tests := []struct {
+		name  string
+		key   []byte
+		token string
+	}{
+		{"other key", []byte("another key"), token},
+		{"other monitor", key, "43.1700000000." + sig},
+		{"other alert", key, "42.1700000001." + sig},
+		{"altered signature", key, "42.1700000000." + sig[1:] + "A"},
+		{"no signature", key, "42.1700000000"},
+		{"empty signature", key, "42.1700000000."},
+		{"empty", key, ""},
+		{"no dot", key, "garbage"},
+		// Signed, but not what AckToken produces
+		{"not a monitor ID", key, "x.1700000000." + ackSignature(key, "x.1700000000")},
+		{"no timestamp", key, "42." + ackSignature(key, "42")},
+		{"negative ID", key, "-1.1700000000." + ackSignature(key, "-1.1700000000")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if id, ts, ok := ParseAckToken(tt.key, tt.token); ok {
+				t.Errorf("ParseAckToken(%q) = %d, %d, true", tt.token, id, ts)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package notify
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Monitor actions that can be triggered from a notification
+const (
+	ActionJoined = "joined" // mark the beta as joined and stop monitoring
+	ActionSnooze = "snooze" // mute notifications for an hour
+	ActionPause  = "pause"  // pause the monitor
+	ActionAck    = "ack"    // acknowledge the alert, stopping repeats and escalation
+)
+
+// Action is a button attached to a notification. It either opens URL or
+// sends Data back to the bot.
+type Action struct {
+	Label string
+	URL   string
+	Data  string
+}
+
+// ActionData encodes an action on a monitor as callback data
+func ActionData(action string, monitorID uint) string {
+	return fmt.Sprintf("m:%s:%d", action, monitorID)
+}
+
+// ParseActionData decodes callback data produced by ActionData
+func ParseActionData(data string) (action string, monitorID uint, ok bool) {
+	parts := strings.Split(data, ":")
+	if len(parts) != 3 || parts[0] != "m" {
+		return "", 0, false
+	}
+	id, err := strconv.ParseUint(parts[2], 10, 32)
+	if err != nil {
+		return "", 0, false
+	}
+	return parts[1], uint(id), true
+}
+
+// LinkActions returns the actions that open a URL, those that work without
+// the bot
+func LinkActions(actions []Action) []Action {
+	var links []Action
+	for _, a := range actions {
+		if a.URL != "" {
+			links = append(links, a)
+		}
+	}
+	return links
+}
+
+// MonitorActions returns the default buttons of an availability alert
+func MonitorActions(monitorID uint, joinURL string) []Action {
+	return []Action{
+		{Label: "Join", URL: joinURL},
+		{Label: "Mark joined & stop", Data: ActionData(ActionJoined, monitorID)},
+		{Label: "Snooze 1h", Data: ActionData(ActionSnooze, monitorID)},
+		{Label: "Pause", Data: ActionData(ActionPause, monitorID)},
+	}
+}
@@ -0,0 +1,29 @@
+package notify
+
+import "testing"
+
+func TestActionData(t *testing.T) {
+	for _, action := range []string{ActionJoined, ActionSnooze, ActionPause, ActionAck} {
+		data := ActionData(action, 42)
+		got, id, ok := ParseActionData(data)
+		if !ok || got != action || id != 42 {
+			t.Errorf("ParseActionData(%q) = %q, %d, %v", data, got, id, ok)
+		}
+	}
+
+	for _, data := range []string{"", "m:ack", "x:ack:1", "m:ack:1:2", "m:ack:abc", "m:ack:-1", "m:ack:4294967296"} {
+		if action, id, ok := ParseActionData(data); ok {
+			t.Errorf("ParseActionData(%q) = %q, %d, true", data, action, id)
+		}
+	}
+}
+
+func TestLinkActions(t *testing.T) {
+	links := LinkActions(MonitorActions(7, "https://testflight.apple.com/join/abcd1234"))
+	if len(links) != 1 || links[0].URL != "https://testflight.apple.com/join/abcd1234" {
+		t.Errorf("LinkActions = %+v, want only the join link", links)
+	}
+	if links := LinkActions([]Action{{Label: "Ack", Data: ActionData(ActionAck, 7)}}); len(links) != 0 {
+		t.Errorf("LinkActions = %+v, want none", links)
+	}
+}
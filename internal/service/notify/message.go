@@ -0,0 +1,130 @@
+package notify
+
+import (
+	"html"
+	"strings"
+)
+
+// Message is a channel independent notification. Channels render it with the
+// escaping their format needs, so user supplied values such as app names can
+// never break the markup.
+type Message struct {
+	Title      string
+	Text       string
+	Fields     []Field
+	Sections   []Section
+	Link       *Link
+	ImageURL   string
+	Actions    []Action
+	Digest     bool     // a summary, channels deliver it to their digest recipients
+	Escalation bool     // an unacknowledged alert, also delivered to escalation recipients
+	Routes     []string // recipient groups it is delivered to, all when empty
+}
+
+// Field is a labelled value shown below the text
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Section is a headed list of links
+type Section struct {
+	Name  string
+	Links []Link
+}
+
+// Link is a labelled URL
+type Link struct {
+	Label string
+	URL   string
+}
+
+// Format is a markup dialect a message can be rendered in
+type Format string
+
+const (
+	FormatPlain      Format = "plain"
+	FormatMarkdownV2 Format = "markdownv2" // Telegram MarkdownV2
+	FormatHTML       Format = "html"       // Telegram HTML subset
+	FormatSlack      Format = "slack"      // Slack mrkdwn
+)
+
+type markup struct {
+	escape func(string) string
+	bold   func(escaped string) string
+	link   func(label, url string) string
+}
+
+var markdownV2Escaper = strings.NewReplacer(
+	`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`,
+	"~", `\~`, "`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`,
+	"|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+)
+
+// Inside the URL part of a MarkdownV2 link only ) and \ must be escaped
+var markdownV2URLEscaper = strings.NewReplacer(`\`, `\\`, ")", `\)`)
+
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+var markups = map[Format]markup{
+	FormatPlain: {
+		escape: func(s string) string { return s },
+		bold:   func(s string) string { return s },
+		link:   func(label, url string) string { return label + ": " + url },
+	},
+	FormatMarkdownV2: {
+		escape: markdownV2Escaper.Replace,
+		bold:   func(s string) string { return "*" + s + "*" },
+		link: func(label, url string) string {
+			return "[" + markdownV2Escaper.Replace(label) + "](" + markdownV2URLEscaper.Replace(url) + ")"
+		},
+	},
+	FormatHTML: {
+		escape: html.EscapeString,
+		bold:   func(s string) string { return "<b>" + s + "</b>" },
+		link: func(label, url string) string {
+			return `<a href="` + html.EscapeString(url) + `">` + html.EscapeString(label) + "</a>"
+		},
+	},
+	FormatSlack: {
+		escape: slackEscaper.Replace,
+		bold:   func(s string) string { return "*" + s + "*" },
+		link: func(label, url string) string {
+			return "<" + slackEscaper.Replace(url) + "|" + slackEscaper.Replace(label) + ">"
+		},
+	},
+}
+
+// Render formats the message, unknown formats fall back to plain text
+func (m Message) Render(format Format) string {
+	mk, ok := markups[format]
+	if !ok {
+		mk = markups[FormatPlain]
+	}
+
+	var sections []string
+	if m.Title != "" {
+		sections = append(sections, mk.bold(mk.escape(m.Title)))
+	}
+	if m.Text != "" {
+		sections = append(sections, mk.escape(m.Text))
+	}
+	if len(m.Fields) > 0 {
+		lines := make([]string, len(m.Fields))
+		for i, f := range m.Fields {
+			lines[i] = mk.bold(mk.escape(f.Name+":")) + " " + mk.escape(f.Value)
+		}
+		sections = append(sections, strings.Join(lines, "\n"))
+	}
+	for _, section := range m.Sections {
+		lines := []string{mk.bold(mk.escape(section.Name))}
+		for _, l := range section.Links {
+			lines = append(lines, "• "+mk.link(l.Label, l.URL))
+		}
+		sections = append(sections, strings.Join(lines, "\n"))
+	}
+	if m.Link != nil && m.Link.URL != "" {
+		sections = append(sections, mk.link(m.Link.Label, m.Link.URL))
+	}
+	return strings.Join(sections, "\n\n")
+}
@@ -0,0 +1,70 @@
+package notify
+
+import "testing"
+
+func TestMessageRender(t *testing.T) {
+	msg := Message{
+		Title:  "Slot open: My_App <beta> (1.2)",
+		Text:   "Join *now* & hurry!",
+		Fields: []Field{{Name: "Status", Value: "a|b"}},
+		Sections: []Section{{
+			Name:  "Apps",
+			Links: []Link{{Label: "[x]", URL: "https://example.com/a_(b)?q=1&r=2"}},
+		}},
+		Link: &Link{Label: "Open", URL: "https://testflight.apple.com/join/abcd1234"},
+	}
+
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{FormatPlain, "Slot open: My_App <beta> (1.2)\n\n" +
+			"Join *now* & hurry!\n\n" +
+			"Status: a|b\n\n" +
+			"Apps\n• [x]: https://example.com/a_(b)?q=1&r=2\n\n" +
+			"Open: https://testflight.apple.com/join/abcd1234"},
+		{FormatMarkdownV2, `*Slot open: My\_App <beta\> \(1\.2\)*` + "\n\n" +
+			`Join \*now\* & hurry\!` + "\n\n" +
+			`*Status:* a\|b` + "\n\n" +
+			"*Apps*\n• " + `[\[x\]](https://example.com/a_(b\)?q=1&r=2)` + "\n\n" +
+			"[Open](https://testflight.apple.com/join/abcd1234)"},
+		{FormatHTML, "<b>Slot open: My_App &lt;beta&gt; (1.2)</b>\n\n" +
+			"Join *now* &amp; hurry!\n\n" +
+			"<b>Status:</b> a|b\n\n" +
+			`<b>Apps</b>` + "\n• " + `<a href="https://example.com/a_(b)?q=1&amp;r=2">[x]</a>` + "\n\n" +
+			`<a href="https://testflight.apple.com/join/abcd1234">Open</a>`},
+		{FormatSlack, "*Slot open: My_App &lt;beta&gt; (1.2)*\n\n" +
+			"Join *now* &amp; hurry!\n\n" +
+			"*Status:* a|b\n\n" +
+			"*Apps*\n• <https://example.com/a_(b)?q=1&amp;r=2|[x]>\n\n" +
+			"<https://testflight.apple.com/join/abcd1234|Open>"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			if got := msg.Render(tt.format); got != tt.want {
+				t.Errorf("Render(%s) =\n%s\nwant\n%s", tt.format, got, tt.want)
+			}
+		})
+	}
+
+	if got, want := msg.Render("unknown"), msg.Render(FormatPlain); got != want {
+		t.Errorf("unknown format = %q, want plain text", got)
+	}
+}
+
+func TestMessageRenderEmpty(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  Message
+		want string
+	}{
+		{"empty", Message{}, ""},
+		{"title only", Message{Title: "Hi"}, "<b>Hi</b>"},
+		{"link without URL", Message{Text: "x", Link: &Link{Label: "Open"}}, "x"},
+	}
+	for _, tt := range tests {
+		if got := tt.msg.Render(FormatHTML); got != tt.want {
+			t.Errorf("%s: Render = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,82 +1,151 @@
 package notify
 
 import (
-	"bytes"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
-	"net/url"
-	"time"
+	"slices"
+	"strconv"
+	"unicode/utf8"
+
+	"tf-monitor/internal/logging"
+	"tf-monitor/internal/model"
+	"tf-monitor/internal/service/telegram"
 )
 
+// Telegram limits photo captions to 1024 characters
+const maxCaptionLength = 1024
+
 // Notifier interface for different notification channels
 type Notifier interface {
-	Send(title, message string) error
+	// Send delivers msg to its recipients except those in delivered, and
+	// returns delivered with the recipients it reached added. A failure for
+	// some recipients does not stop delivery to the others, a retry with the
+	// returned list only sends to those that failed.
+	Send(msg Message, delivered []string) ([]string, error)
 }
 
 // TelegramNotifier sends notifications via Telegram bot
 type TelegramNotifier struct {
 	BotToken string
-	ChatID   string
-	client   *http.Client
+	Targets  []model.TelegramTarget
+	SendIcon bool
+	// Callbacks is set when the interactive bot runs with this token and
+	// receives the presses of callback buttons, others are left out
+	Callbacks bool
+	client    *telegram.Client
 }
 
 // NewTelegramNotifier creates a new Telegram notifier
-func NewTelegramNotifier(botToken, chatID string, proxyURL string) *TelegramNotifier {
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+func NewTelegramNotifier(botToken string, targets []model.TelegramTarget, sendIcon bool, proxyURL string) *TelegramNotifier {
+	return &TelegramNotifier{
+		BotToken: botToken,
+		Targets:  targets,
+		SendIcon: sendIcon,
+		client:   telegram.NewClient(botToken, proxyURL),
 	}
+}
 
-	if proxyURL != "" {
-		proxy, err := url.Parse(proxyURL)
-		if err == nil {
-			client.Transport = &http.Transport{
-				Proxy: http.ProxyURL(proxy),
-			}
-		}
+// Send delivers the message to every target, digests only to digest targets
+// and alerts to the others. Escalation targets only receive escalated alerts,
+// and messages with routes only reach targets in those groups. Targets are
+// recorded in delivered by chat and topic.
+func (t *TelegramNotifier) Send(msg Message, delivered []string) ([]string, error) {
+	if t.BotToken == "" || len(t.Targets) == 0 {
+		return delivered, fmt.Errorf("telegram not configured")
 	}
 
-	return &TelegramNotifier{
-		BotToken: botToken,
-		ChatID:   chatID,
-		client:   client,
+	var errs []error
+	for _, target := range t.Targets {
+		if target.Digest != msg.Digest || (target.Escalation && !msg.Escalation) {
+			continue
+		}
+		if len(msg.Routes) > 0 && !slices.Contains(msg.Routes, target.Route()) {
+			continue
+		}
+		key := targetKey(target)
+		if slices.Contains(delivered, key) {
+			continue
+		}
+		if err := t.sendTo(target, msg); err != nil {
+			errs = append(errs, fmt.Errorf("chat %s: %w", target.ChatID, err))
+			continue
+		}
+		delivered = append(delivered, key)
 	}
+	return delivered, errors.Join(errs...)
 }
 
-// Send sends a message via Telegram
-func (t *TelegramNotifier) Send(title, message string) error {
-	if t.BotToken == "" || t.ChatID == "" {
-		return fmt.Errorf("telegram not configured")
+// targetKey names a target in the delivered list of Send
+func targetKey(target model.TelegramTarget) string {
+	if target.ThreadID != 0 {
+		return "telegram:" + target.ChatID + "/" + strconv.Itoa(target.ThreadID)
 	}
+	return "telegram:" + target.ChatID
+}
 
-	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", t.BotToken)
-
+func (t *TelegramNotifier) sendTo(target model.TelegramTarget, msg Message) error {
+	text := msg.Render(FormatHTML)
 	payload := map[string]interface{}{
-		"chat_id":    t.ChatID,
-		"text":       fmt.Sprintf("*%s*\n\n%s", title, message),
-		"parse_mode": "Markdown",
+		"chat_id":              target.ChatID,
+		"parse_mode":           "HTML",
+		"disable_notification": target.Silent,
 	}
-
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return err
+	if target.ThreadID != 0 {
+		payload["message_thread_id"] = target.ThreadID
 	}
-
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return err
+	actions := msg.Actions
+	if !t.Callbacks {
+		actions = LinkActions(actions)
 	}
-	req.Header.Set("Content-Type", "application/json")
+	if len(actions) > 0 {
+		payload["reply_markup"] = Keyboard(actions)
+	}
+
+	// The limit applies to the text left after parsing the markup
+	if t.SendIcon && msg.ImageURL != "" && utf8.RuneCountInString(msg.Render(FormatPlain)) <= maxCaptionLength {
+		payload["photo"] = msg.ImageURL
+		payload["caption"] = text
+		err := t.client.Call("sendPhoto", payload, nil)
 
-	resp, err := t.client.Do(req)
-	if err != nil {
-		return err
+		// Fall back to text when Telegram cannot fetch the icon
+		var apiErr *telegram.APIError
+		if err == nil || !errors.As(err, &apiErr) || apiErr.Code != http.StatusBadRequest {
+			return err
+		}
+		slog.Warn("Failed to send icon, sending text", "chat_id", target.ChatID, logging.Err(err))
+		delete(payload, "photo")
+		delete(payload, "caption")
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("telegram API returned %d", resp.StatusCode)
+	payload["text"] = text
+	return t.client.Call("sendMessage", payload, nil)
+}
+
+// Keyboard lays out actions as a Telegram inline keyboard. URL actions share
+// the first row, callback actions follow two per row.
+func Keyboard(actions []Action) telegram.InlineKeyboardMarkup {
+	var links, callbacks []telegram.InlineKeyboardButton
+	for _, a := range actions {
+		button := telegram.InlineKeyboardButton{Text: a.Label, URL: a.URL, CallbackData: a.Data}
+		if a.URL != "" {
+			links = append(links, button)
+		} else {
+			callbacks = append(callbacks, button)
+		}
 	}
 
-	return nil
+	var rows [][]telegram.InlineKeyboardButton
+	if len(links) > 0 {
+		rows = append(rows, links)
+	}
+	for i := 0; i < len(callbacks); i += 2 {
+		end := i + 2
+		if end > len(callbacks) {
+			end = len(callbacks)
+		}
+		rows = append(rows, callbacks[i:end])
+	}
+	return telegram.InlineKeyboardMarkup{InlineKeyboard: rows}
 }
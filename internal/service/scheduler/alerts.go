@@ -0,0 +1,81 @@
+package scheduler
+
+import (
+	"log/slog"
+
+	"tf-monitor/internal/logging"
+	"tf-monitor/internal/model"
+	"tf-monitor/internal/service/events"
+	"tf-monitor/internal/service/notify"
+)
+
+// alert queues the alert a check calls for according to the notify mode and
+// policy of the monitor. It is queued in the transaction of the check result,
+// so it is delivered even if sending fails now or the process restarts.
+func (s *Scheduler) alert(e events.CheckCompleted) error {
+	m := e.Monitor
+	if e.Err != nil || !e.Available || s.notifierFor(m.UserID) == nil {
+		return nil
+	}
+	if m.SnoozedUntil != nil && e.Started.Before(*m.SnoozedUntil) {
+		return nil
+	}
+
+	shouldNotify := false
+	switch m.NotifyMode {
+	case model.NotifyLoop:
+		shouldNotify = m.AckedAt == nil
+	case model.NotifyOnce:
+		shouldNotify = !m.Notified
+	case model.NotifyOnlyAvailable:
+		shouldNotify = e.Previous != model.StatusAvailable
+	}
+	if !shouldNotify {
+		return nil
+	}
+
+	deliverAt, reason := s.admit(e.Tx.DB, m, e.Started)
+	if reason != "" {
+		slog.Info("Alert suppressed", logging.MonitorID(m.ID), logging.AppID(m.AppID), "reason", reason)
+		return nil
+	}
+
+	// Repeats of an alert keep the time of the first one, acknowledgement and
+	// escalation refer to it
+	alertedAt := e.Started
+	if m.AlertedAt != nil {
+		alertedAt = *m.AlertedAt
+	}
+	iconURL := e.IconURL
+	if iconURL == "" {
+		iconURL = m.IconURL
+	}
+	msg := notify.Message{
+		Title:    "🎉 TestFlight 有位了!",
+		Text:     e.Message,
+		Fields:   []notify.Field{{Name: "App", Value: e.AppName}},
+		Link:     &notify.Link{Label: "点击加入", URL: m.TestFlightURL},
+		ImageURL: iconURL,
+		Actions:  s.alertActions(m, alertedAt),
+		Routes:   m.AlertRoutes(),
+	}
+	n := model.Notification{
+		UserID:        m.UserID,
+		MonitorID:     m.ID,
+		AppID:         m.AppID,
+		Channel:       ChannelTelegram,
+		NextAttemptAt: deliverAt,
+	}
+	if err := enqueue(e.Tx.DB, n, msg); err != nil {
+		return err
+	}
+	if err := e.Tx.Model(m).Updates(map[string]interface{}{
+		"notified":   true,
+		"alerted_at": alertedAt,
+	}).Error; err != nil {
+		return err
+	}
+
+	e.Tx.AfterCommit(s.wakeDispatcher)
+	return nil
+}
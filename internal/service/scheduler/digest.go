@@ -0,0 +1,149 @@
+package scheduler
+
+import (
+	"log/slog"
+	"slices"
+	"time"
+
+	"tf-monitor/internal/logging"
+	"tf-monitor/internal/model"
+	"tf-monitor/internal/repository"
+	"tf-monitor/internal/service/events"
+	"tf-monitor/internal/service/notify"
+
+	"gorm.io/gorm"
+)
+
+const eventRetention = 30 * 24 * time.Hour
+
+// digestSection groups the events of one kind in a digest
+type digestSection struct {
+	name  string
+	match func(e *model.MonitorEvent) bool
+}
+
+var digestSections = []digestSection{
+	{"🟢 有位", func(e *model.MonitorEvent) bool { return e.To == model.StatusAvailable }},
+	{"🔴 已满", func(e *model.MonitorEvent) bool {
+		return e.From == model.StatusAvailable && e.To == model.StatusFull
+	}},
+	{"⚠️ 出错", func(e *model.MonitorEvent) bool { return e.To == model.StatusError }},
+	{"⌛ 已过期", func(e *model.MonitorEvent) bool { return e.To == model.StatusExpired }},
+}
+
+// recordEvent stores a status change of m as part of tx if digests report it
+func recordEvent(tx *gorm.DB, m *model.Monitor, from, to model.MonitorStatus) error {
+	if from == to {
+		return nil
+	}
+	for _, section := range digestSections {
+		e := model.MonitorEvent{
+			UserID:        m.UserID,
+			MonitorID:     m.ID,
+			AppID:         m.AppID,
+			AppName:       m.AppName,
+			TestFlightURL: m.TestFlightURL,
+			From:          from,
+			To:            to,
+		}
+		if section.match(&e) {
+			return tx.Create(&e).Error
+		}
+	}
+	return nil
+}
+
+// recordStatusChange keeps the status changes digests report
+func recordStatusChange(e events.StatusChanged) error {
+	return recordEvent(e.Tx.DB, e.Monitor, e.From, e.To)
+}
+
+// recordExpiry keeps the expiry of a monitor for digests
+func recordExpiry(e events.MonitorExpired) error {
+	return recordEvent(e.Tx.DB, e.Monitor, e.Previous, model.StatusExpired)
+}
+
+// sendDigests queues the digests that are due at now
+func (s *Scheduler) sendDigests(now time.Time) {
+	var cfgs []model.TelegramConfig
+	repository.GetDB().Where("enabled = ? AND digest_schedule <> ''", true).Find(&cfgs)
+
+	for i := range cfgs {
+		cfg := &cfgs[i]
+		if !slices.ContainsFunc(cfg.Targets, func(t model.TelegramTarget) bool { return t.Digest }) {
+			continue
+		}
+		// The first digest covers the time from now on
+		if cfg.LastDigestAt == nil {
+			repository.GetDB().Model(cfg).Update("last_digest_at", now)
+			continue
+		}
+		if !cfg.DigestDue(now) {
+			continue
+		}
+		if err := enqueueDigest(cfg, now); err != nil {
+			slog.Error("Failed to queue digest", "user_id", cfg.UserID, logging.Err(err))
+		}
+	}
+}
+
+// enqueueDigest summarises the events since the last digest, nothing is sent
+// when nothing happened
+func enqueueDigest(cfg *model.TelegramConfig, now time.Time) error {
+	return repository.GetDB().Transaction(func(tx *gorm.DB) error {
+		var events []model.MonitorEvent
+		tx.Where("user_id = ? AND created_at > ? AND created_at <= ?", cfg.UserID, *cfg.LastDigestAt, now).
+			Order("created_at asc").
+			Find(&events)
+
+		if len(events) > 0 {
+			msg := buildDigest(events, *cfg.LastDigestAt, now, cfg.DigestLocation())
+			n := model.Notification{
+				UserID:        cfg.UserID,
+				Channel:       ChannelTelegram,
+				NextAttemptAt: now,
+			}
+			if err := enqueue(tx, n, msg); err != nil {
+				return err
+			}
+		}
+		return tx.Model(cfg).Update("last_digest_at", now).Error
+	})
+}
+
+func buildDigest(events []model.MonitorEvent, from, to time.Time, loc *time.Location) notify.Message {
+	msg := notify.Message{
+		Title:  "📋 TestFlight 监控摘要",
+		Text:   from.In(loc).Format("01-02 15:04") + " – " + to.In(loc).Format("01-02 15:04"),
+		Digest: true,
+	}
+
+	for _, section := range digestSections {
+		listed := make(map[uint]bool)
+		var links []notify.Link
+		for i := range events {
+			e := &events[i]
+			if listed[e.MonitorID] || !section.match(e) {
+				continue
+			}
+			listed[e.MonitorID] = true
+
+			label := e.AppName
+			if label == "" {
+				label = e.AppID
+			}
+			links = append(links, notify.Link{Label: label, URL: e.TestFlightURL})
+		}
+		if len(links) > 0 {
+			msg.Sections = append(msg.Sections, notify.Section{Name: section.name, Links: links})
+		}
+	}
+	return msg
+}
+
+// pruneEvents removes status changes past the retention period
+func pruneEvents() {
+	repository.GetDB().Unscoped().
+		Where("created_at < ?", time.Now().Add(-eventRetention)).
+		Delete(&model.MonitorEvent{})
+}
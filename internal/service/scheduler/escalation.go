@@ -0,0 +1,113 @@
+package scheduler
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"tf-monitor/internal/logging"
+	"tf-monitor/internal/model"
+	"tf-monitor/internal/repository"
+	"tf-monitor/internal/service/notify"
+
+	"gorm.io/gorm"
+)
+
+// alertActions returns the buttons of an alert raised at alertedAt. Monitors
+// that repeat or escalate alerts get an acknowledge button, a signed link when
+// a public URL is set so it works without the bot.
+func (s *Scheduler) alertActions(m *model.Monitor, alertedAt time.Time) []notify.Action {
+	actions := notify.MonitorActions(m.ID, m.TestFlightURL)
+	if m.NotifyMode != model.NotifyLoop && m.EscalateAfter <= 0 {
+		return actions
+	}
+
+	ack := notify.Action{Label: "Acknowledge", Data: notify.ActionData(notify.ActionAck, m.ID)}
+	if url := s.ackURL(m.ID, alertedAt); url != "" {
+		ack = notify.Action{Label: "Acknowledge", URL: url}
+	}
+	return append(actions, ack)
+}
+
+// ackURL returns the signed acknowledgement link of an alert, or an empty
+// string when no public URL is set
+func (s *Scheduler) ackURL(monitorID uint, alertedAt time.Time) string {
+	if s.publicURL == "" {
+		return ""
+	}
+	key, err := repository.SigningKey()
+	if err != nil {
+		slog.Error("Failed to load signing key", logging.Err(err))
+		return ""
+	}
+	return s.publicURL + "/api/ack/" + notify.AckToken(key, monitorID, alertedAt)
+}
+
+// escalate resends the alerts that were delivered but not acknowledged in
+// time, also to the escalation recipients. Each alert is escalated once.
+func (s *Scheduler) escalate(now time.Time) {
+	var monitors []model.Monitor
+	repository.GetDB().
+		Where("escalate_after > 0 AND alerted_at IS NOT NULL AND acked_at IS NULL AND escalated = ?", false).
+		Where("enabled = ? AND status = ?", true, model.StatusAvailable).
+		Find(&monitors)
+
+	for i := range monitors {
+		m := &monitors[i]
+		if m.SnoozedUntil != nil && now.Before(*m.SnoozedUntil) {
+			continue
+		}
+
+		// Counted from the delivery, alerts held back by quiet hours have not
+		// been seen yet
+		var first model.Notification
+		err := repository.GetDB().
+			Where("monitor_id = ? AND status = ? AND created_at >= ?", m.ID, model.NotificationSent, *m.AlertedAt).
+			Order("created_at asc").
+			First(&first).Error
+		if err != nil || first.SentAt == nil {
+			continue
+		}
+		if now.Sub(*first.SentAt) < time.Duration(m.EscalateAfter)*time.Minute {
+			continue
+		}
+
+		if err := s.enqueueEscalation(m, now); err != nil {
+			slog.Error("Failed to escalate alert", logging.MonitorID(m.ID), logging.AppID(m.AppID), logging.Err(err))
+			continue
+		}
+		slog.Info("Alert escalated", logging.MonitorID(m.ID), logging.AppID(m.AppID))
+		s.wakeDispatcher()
+	}
+}
+
+func (s *Scheduler) enqueueEscalation(m *model.Monitor, now time.Time) error {
+	label := m.AppName
+	if label == "" {
+		label = m.AppID
+	}
+	msg := notify.Message{
+		Title:      "🚨 TestFlight 有位了，提醒尚未确认!",
+		Text:       fmt.Sprintf("提醒已发出 %d 分钟仍未确认", int(now.Sub(*m.AlertedAt).Minutes())),
+		Fields:     []notify.Field{{Name: "App", Value: label}},
+		Link:       &notify.Link{Label: "点击加入", URL: m.TestFlightURL},
+		ImageURL:   m.IconURL,
+		Actions:    s.alertActions(m, *m.AlertedAt),
+		Escalation: true,
+		Routes:     m.AlertRoutes(),
+	}
+
+	return repository.GetDB().Transaction(func(tx *gorm.DB) error {
+		n := model.Notification{
+			UserID:        m.UserID,
+			MonitorID:     m.ID,
+			AppID:         m.AppID,
+			Channel:       ChannelTelegram,
+			NextAttemptAt: now,
+		}
+		if err := enqueue(tx, n, msg); err != nil {
+			return err
+		}
+		return tx.Model(m).Update("escalated", true).Error
+	})
+}
@@ -0,0 +1,35 @@
+package scheduler
+
+import (
+	"strconv"
+	"time"
+
+	"tf-monitor/internal/metrics"
+)
+
+func (s *Scheduler) registerMetrics() {
+	metrics.NewGaugeFunc("tfmonitor_active_jobs",
+		"Monitors that are being checked.",
+		func() []metrics.Sample {
+			return []metrics.Sample{{Value: float64(s.GetActiveJobCount())}}
+		})
+
+	metrics.NewGaugeFunc("tfmonitor_seconds_since_last_successful_check",
+		"Seconds since the last successful check of each active monitor, counted from the job start before the first one.",
+		func() []metrics.Sample {
+			s.mu.RLock()
+			defer s.mu.RUnlock()
+
+			samples := make([]metrics.Sample, 0, len(s.jobs))
+			for id, job := range s.jobs {
+				if !job.Running {
+					continue
+				}
+				samples = append(samples, metrics.Sample{
+					Values: []string{strconv.FormatUint(uint64(id), 10)},
+					Value:  time.Since(job.LastSuccess).Seconds(),
+				})
+			}
+			return samples
+		}, "monitor_id")
+}
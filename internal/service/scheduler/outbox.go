@@ -0,0 +1,203 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"tf-monitor/internal/logging"
+	"tf-monitor/internal/model"
+	"tf-monitor/internal/repository"
+	"tf-monitor/internal/service/events"
+	"tf-monitor/internal/service/notify"
+	"tf-monitor/internal/service/telegram"
+
+	"gorm.io/gorm"
+)
+
+// Notification channels an outbox entry can be delivered through
+const (
+	ChannelTelegram = "telegram"
+)
+
+const (
+	dispatchInterval = 5 * time.Second
+	dispatchBatch    = 50
+	maxAttempts      = 10
+	baseBackoff      = 10 * time.Second
+	maxBackoff       = 10 * time.Minute
+	outboxRetention  = 7 * 24 * time.Hour
+	timedInterval    = time.Minute // how often digests, escalations and missing jobs are looked at
+)
+
+// enqueue stores msg in the outbox as part of tx. n names the recipient and
+// channel, and NextAttemptAt when it is sent.
+func enqueue(tx *gorm.DB, n model.Notification, msg notify.Message) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	n.Title = msg.Title
+	n.Payload = string(payload)
+	n.Status = model.NotificationPending
+	return tx.Create(&n).Error
+}
+
+// wakeDispatcher makes the dispatcher run now instead of at its next tick
+func (s *Scheduler) wakeDispatcher() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Scheduler) runDispatcher() {
+	ticker := time.NewTicker(dispatchInterval)
+	defer ticker.Stop()
+
+	var lastTimed, lastPrune time.Time
+	for {
+		if time.Since(lastTimed) >= timedInterval {
+			lastTimed = time.Now()
+			s.reconcile()
+			s.sendDigests(lastTimed)
+			s.escalate(lastTimed)
+		}
+		s.dispatch()
+		if time.Since(lastPrune) > time.Hour {
+			pruneOutbox()
+			pruneEvents()
+			lastPrune = time.Now()
+		}
+
+		select {
+		case <-s.stopChan:
+			return
+		case <-s.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatch delivers the notifications that are due
+func (s *Scheduler) dispatch() {
+	var due []model.Notification
+	repository.GetDB().
+		Where("status = ? AND next_attempt_at <= ?", model.NotificationPending, time.Now()).
+		Order("next_attempt_at asc").
+		Limit(dispatchBatch).
+		Find(&due)
+
+	for i := range due {
+		s.deliver(&due[i])
+	}
+}
+
+// deliver sends a notification and records the result. Recipients reached are
+// remembered, a retry only goes to those that failed.
+func (s *Scheduler) deliver(n *model.Notification) {
+	sendErr := s.send(n)
+	n.Attempts++
+
+	updates := map[string]interface{}{"attempts": n.Attempts}
+	if delivered, err := json.Marshal(n.Delivered); err == nil {
+		updates["delivered"] = string(delivered)
+	}
+	switch {
+	case sendErr == nil:
+		updates["status"] = model.NotificationSent
+		updates["sent_at"] = time.Now()
+		updates["last_error"] = ""
+		slog.Info("Notification sent", "notification_id", n.ID, logging.MonitorID(n.MonitorID), logging.Channel(n.Channel))
+	case n.Attempts >= maxAttempts:
+		updates["status"] = model.NotificationFailed
+		updates["last_error"] = sendErr.Error()
+		slog.Error("Notification failed, giving up", "notification_id", n.ID, logging.MonitorID(n.MonitorID),
+			logging.Channel(n.Channel), "attempts", n.Attempts, logging.Err(sendErr))
+	default:
+		delay := backoff(n.Attempts, sendErr)
+		updates["last_error"] = sendErr.Error()
+		updates["next_attempt_at"] = time.Now().Add(delay)
+		slog.Warn("Notification failed, retrying", "notification_id", n.ID, logging.MonitorID(n.MonitorID),
+			logging.Channel(n.Channel), "retry_in", delay.String(), logging.Err(sendErr))
+	}
+
+	err := events.Transaction(repository.GetDB(), func(tx *events.Tx) error {
+		if err := tx.Model(n).Updates(updates).Error; err != nil {
+			return err
+		}
+		return s.bus.NotificationSent.Publish(events.NotificationSent{Tx: tx, Notification: n, Err: sendErr})
+	})
+	if err != nil {
+		slog.Error("Failed to save delivery result", "notification_id", n.ID, logging.MonitorID(n.MonitorID), logging.Err(err))
+	}
+}
+
+// send delivers n to the recipients not reached yet and adds those it
+// reaches to n.Delivered
+func (s *Scheduler) send(n *model.Notification) error {
+	var msg notify.Message
+	if err := json.Unmarshal([]byte(n.Payload), &msg); err != nil {
+		return err
+	}
+
+	switch n.Channel {
+	case ChannelTelegram:
+		notifier := s.notifierFor(n.UserID)
+		if notifier == nil {
+			return fmt.Errorf("telegram notifications are disabled")
+		}
+		var duplicates []string
+		if !msg.Digest && !msg.Escalation {
+			duplicates = s.alertedRecently(n)
+		}
+		reached, err := notifier.Send(msg, append(slices.Clone(n.Delivered), duplicates...))
+		for _, recipient := range reached {
+			if !slices.Contains(duplicates, recipient) && !slices.Contains(n.Delivered, recipient) {
+				n.Delivered = append(n.Delivered, recipient)
+			}
+		}
+		return err
+	}
+	return fmt.Errorf("unknown channel %q", n.Channel)
+}
+
+// alertedRecently returns the recipients that got an alert for the app of n
+// from another monitor within the dedup window, such as a group chat shared
+// by users who both monitor the app
+func (s *Scheduler) alertedRecently(n *model.Notification) []string {
+	if s.dedupWindow <= 0 || n.AppID == "" {
+		return nil
+	}
+	var others []model.Notification
+	repository.GetDB().Select("delivered").
+		Where("app_id = ? AND monitor_id <> ? AND updated_at > ?", n.AppID, n.MonitorID, time.Now().Add(-s.dedupWindow)).
+		Find(&others)
+
+	var recipients []string
+	for _, other := range others {
+		recipients = append(recipients, other.Delivered...)
+	}
+	return recipients
+}
+
+// backoff doubles the delay with every attempt and waits at least as long as
+// Telegram asked for when rate limited
+func backoff(attempts int, err error) time.Duration {
+	delay := maxBackoff
+	if attempts < 10 {
+		delay = min(baseBackoff<<(attempts-1), maxBackoff)
+	}
+
+	return max(delay, telegram.RetryAfter(err))
+}
+
+// pruneOutbox removes finished notifications past the retention period
+func pruneOutbox() {
+	repository.GetDB().Unscoped().
+		Where("status <> ? AND updated_at < ?", model.NotificationPending, time.Now().Add(-outboxRetention)).
+		Delete(&model.Notification{})
+}
@@ -0,0 +1,49 @@
+package scheduler
+
+import (
+	"time"
+
+	"tf-monitor/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// admit applies the notification policy to an alert of m raised at now. It
+// returns when the alert may be delivered, or why it is suppressed.
+func (s *Scheduler) admit(tx *gorm.DB, m *model.Monitor, now time.Time) (deliverAt time.Time, reason string) {
+	policy := m.Policy.Merge(s.policy)
+
+	// Failed alerts never reached anyone and do not count
+	counted := func() *gorm.DB {
+		return tx.Model(&model.Notification{}).Where("status <> ?", model.NotificationFailed)
+	}
+
+	var count int64
+	counted().Where("monitor_id = ? AND status = ?", m.ID, model.NotificationPending).Count(&count)
+	if count > 0 {
+		return time.Time{}, "previous alert not delivered yet"
+	}
+
+	if policy.MinRepeatInterval > 0 {
+		since := now.Add(-time.Duration(policy.MinRepeatInterval) * time.Second)
+		counted().Where("monitor_id = ? AND created_at > ?", m.ID, since).Count(&count)
+		if count > 0 {
+			return time.Time{}, "minimum repeat interval"
+		}
+	}
+
+	if policy.MaxPerHour > 0 {
+		counted().Where("monitor_id = ? AND created_at > ?", m.ID, now.Add(-time.Hour)).Count(&count)
+		if count >= int64(policy.MaxPerHour) {
+			return time.Time{}, "hourly limit reached"
+		}
+	}
+
+	if until := policy.QuietUntil(now); !until.IsZero() {
+		if policy.QuietMode == model.QuietDrop {
+			return time.Time{}, "quiet hours"
+		}
+		return until, ""
+	}
+	return now, ""
+}
@@ -1,31 +1,40 @@
 package scheduler
 
 import (
-	"fmt"
-	"log"
+	"log/slog"
 	"sync"
 	"time"
 
+	"tf-monitor/internal/logging"
 	"tf-monitor/internal/model"
 	"tf-monitor/internal/repository"
+	"tf-monitor/internal/service/events"
 	"tf-monitor/internal/service/monitor"
 	"tf-monitor/internal/service/notify"
 )
 
 type Scheduler struct {
 	checker     *monitor.Checker
-	notifier    notify.Notifier
+	notifiers   map[uint]notify.Notifier // keyed by user ID
 	proxyURL    string
 	mu          sync.RWMutex
 	jobs        map[uint]*Job
 	stopChan    chan struct{}
+	wake        chan struct{} // triggers an immediate outbox dispatch
+	policy      model.NotifyPolicy
+	dedupWindow time.Duration
+	publicURL   string // base of links in notifications, none are added when empty
+	botToken    string // of the bot that receives button presses, see SetBotToken
+	bus         *events.Bus
+	running     bool
 	nextCheckAt time.Time
 }
 
 type Job struct {
-	MonitorID uint
-	StopChan  chan struct{}
-	Running   bool
+	MonitorID   uint
+	StopChan    chan struct{}
+	Running     bool
+	LastSuccess time.Time // last successful check, or when the job started
 }
 
 var instance *Scheduler
@@ -34,24 +43,83 @@ var once sync.Once
 func GetScheduler() *Scheduler {
 	once.Do(func() {
 		instance = &Scheduler{
-			jobs:     make(map[uint]*Job),
-			stopChan: make(chan struct{}),
+			jobs:      make(map[uint]*Job),
+			notifiers: make(map[uint]notify.Notifier),
+			stopChan:  make(chan struct{}),
+			wake:      make(chan struct{}, 1),
+			bus:       events.GetBus(),
 		}
+		instance.registerMetrics()
+		instance.subscribe()
 	})
 	return instance
 }
 
+// subscribe attaches the scheduler's reactions to monitor events
+func (s *Scheduler) subscribe() {
+	s.bus.MonitorCreated.Subscribe(s.startCreated)
+	s.bus.CheckCompleted.Subscribe(s.alert)
+	s.bus.StatusChanged.Subscribe(recordStatusChange)
+	s.bus.MonitorExpired.Subscribe(recordExpiry)
+}
+
+// startCreated starts checking a monitor created enabled once it is saved
+func (s *Scheduler) startCreated(e events.MonitorCreated) error {
+	if e.Monitor.Enabled {
+		id := e.Monitor.ID
+		e.Tx.AfterCommit(func() { s.StartJob(id) })
+	}
+	return nil
+}
+
 func (s *Scheduler) Init(proxyURL string) {
 	s.proxyURL = proxyURL
 	s.checker = monitor.NewChecker(proxyURL)
 }
 
-func (s *Scheduler) UpdateNotifier(botToken, chatID string) {
-	s.notifier = notify.NewTelegramNotifier(botToken, chatID, s.proxyURL)
+// SetNotifyPolicy sets the global policy and the window in which a chat that
+// got an alert for an app is skipped by alerts of other monitors of the app
+func (s *Scheduler) SetNotifyPolicy(policy model.NotifyPolicy, dedupWindow time.Duration) {
+	s.policy = policy
+	s.dedupWindow = dedupWindow
+}
+
+// SetPublicURL sets the address links in notifications point to
+func (s *Scheduler) SetPublicURL(url string) {
+	s.publicURL = url
+}
+
+// SetBotToken sets the token of the interactive bot. Only that bot receives
+// the presses of callback buttons, alerts sent by other bots leave them out.
+func (s *Scheduler) SetBotToken(token string) {
+	s.botToken = token
+}
+
+func (s *Scheduler) UpdateNotifier(cfg *model.TelegramConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	notifier := notify.NewTelegramNotifier(string(cfg.BotToken), cfg.Targets, cfg.SendIcon, s.proxyURL)
+	notifier.Callbacks = s.botToken != "" && string(cfg.BotToken) == s.botToken
+	s.notifiers[cfg.UserID] = notifier
+}
+
+func (s *Scheduler) RemoveNotifier(userID uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.notifiers, userID)
+}
+
+func (s *Scheduler) notifierFor(userID uint) notify.Notifier {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.notifiers[userID]
 }
 
 func (s *Scheduler) Start() {
-	log.Println("Scheduler started")
+	s.mu.Lock()
+	s.running = true
+	s.mu.Unlock()
+	slog.Info("Scheduler started")
 
 	var monitors []model.Monitor
 	repository.GetDB().Where("enabled = ?", true).Find(&monitors)
@@ -59,6 +127,8 @@ func (s *Scheduler) Start() {
 	for _, m := range monitors {
 		s.StartJob(m.ID)
 	}
+
+	go s.runDispatcher()
 }
 
 func (s *Scheduler) Stop() {
@@ -72,21 +142,55 @@ func (s *Scheduler) Stop() {
 		}
 		delete(s.jobs, id)
 	}
-	log.Println("Scheduler stopped")
+	close(s.stopChan)
+	s.running = false
+	slog.Info("Scheduler stopped")
 }
 
+// StartJob checks the monitor now and then at its interval. Until the
+// scheduler is started it does nothing, Start picks up the enabled monitors.
 func (s *Scheduler) StartJob(monitorID uint) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if !s.running {
+		return
+	}
 	if job, exists := s.jobs[monitorID]; exists && job.Running {
 		close(job.StopChan)
 	}
+	s.startJobLocked(monitorID)
+}
+
+// reconcile starts jobs for enabled monitors that have none, those added or
+// resumed from the command line while the server runs
+func (s *Scheduler) reconcile() {
+	var ids []uint
+	if err := repository.GetDB().Model(&model.Monitor{}).Where("enabled = ?", true).Pluck("id", &ids).Error; err != nil {
+		slog.Error("Failed to load enabled monitors", logging.Err(err))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.running {
+		return
+	}
+	for _, id := range ids {
+		if _, exists := s.jobs[id]; !exists {
+			slog.Info("Starting job of monitor enabled outside the server", logging.MonitorID(id))
+			s.startJobLocked(id)
+		}
+	}
+}
 
+// startJobLocked starts a job for the monitor, s.mu must be held
+func (s *Scheduler) startJobLocked(monitorID uint) {
 	job := &Job{
-		MonitorID: monitorID,
-		StopChan:  make(chan struct{}),
-		Running:   true,
+		MonitorID:   monitorID,
+		StopChan:    make(chan struct{}),
+		Running:     true,
+		LastSuccess: time.Now(),
 	}
 	s.jobs[monitorID] = job
 
@@ -108,20 +212,24 @@ func (s *Scheduler) runJob(job *Job) {
 	for {
 		var m model.Monitor
 		if err := repository.GetDB().First(&m, job.MonitorID).Error; err != nil {
-			log.Printf("Monitor %d not found, stopping job", job.MonitorID)
+			slog.Warn("Monitor not found, stopping job", logging.MonitorID(job.MonitorID))
+			s.removeJob(job)
+			return
+		}
+		// Paused outside this process, e.g. from the command line
+		if !m.Enabled {
+			slog.Info("Monitor disabled, stopping job", logging.MonitorID(job.MonitorID))
+			s.removeJob(job)
 			return
 		}
 
 		if m.ExpireAt != nil && time.Now().After(*m.ExpireAt) {
-			repository.GetDB().Model(&m).Updates(map[string]interface{}{
-				"enabled": false,
-				"status":  model.StatusExpired,
-			})
-			log.Printf("Monitor %d expired", job.MonitorID)
+			s.expire(&m)
+			s.removeJob(job)
 			return
 		}
 
-		s.performCheck(&m)
+		ok := s.performCheck(&m)
 
 		interval := time.Duration(m.Interval) * time.Second
 		if interval < 10*time.Second {
@@ -130,85 +238,136 @@ func (s *Scheduler) runJob(job *Job) {
 
 		s.mu.Lock()
 		s.nextCheckAt = time.Now().Add(interval)
+		if ok {
+			job.LastSuccess = time.Now()
+		}
 		s.mu.Unlock()
 
 		select {
 		case <-job.StopChan:
-			log.Printf("Job for monitor %d stopped", job.MonitorID)
+			slog.Info("Job stopped", logging.MonitorID(job.MonitorID))
 			return
 		case <-time.After(interval):
 		}
 	}
 }
 
-func (s *Scheduler) performCheck(m *model.Monitor) {
-	now := time.Now()
+// removeJob forgets a job that ended by itself, so reconcile starts a new one
+// once the monitor is enabled again
+func (s *Scheduler) removeJob(job *Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// A resume may have replaced the job meanwhile
+	if s.jobs[job.MonitorID] == job {
+		job.Running = false
+		delete(s.jobs, job.MonitorID)
+	}
+}
 
-	repository.GetDB().Model(m).Updates(map[string]interface{}{
-		"status":     model.StatusChecking,
-		"last_check": now,
+// expire disables m at the end of its duration
+func (s *Scheduler) expire(m *model.Monitor) {
+	prevStatus := m.Status
+	err := events.Transaction(repository.GetDB(), func(tx *events.Tx) error {
+		if err := tx.Model(m).Updates(map[string]interface{}{
+			"enabled": false,
+			"status":  model.StatusExpired,
+		}).Error; err != nil {
+			return err
+		}
+		return s.bus.MonitorExpired.Publish(events.MonitorExpired{Tx: tx, Monitor: m, Previous: prevStatus})
 	})
-
-	info, err := s.checker.Check(m.AppID)
 	if err != nil {
-		repository.GetDB().Model(m).Updates(map[string]interface{}{
-			"status":     model.StatusError,
-			"last_error": err.Error(),
-		})
-		log.Printf("Check failed for %s: %v", m.AppID, err)
+		slog.Error("Failed to expire monitor", logging.MonitorID(m.ID), logging.AppID(m.AppID), logging.Err(err))
 		return
 	}
+	slog.Info("Monitor expired", logging.MonitorID(m.ID), logging.AppID(m.AppID))
+}
 
-	if m.AppName == "" && info.AppName != "" {
-		repository.GetDB().Model(m).Updates(map[string]interface{}{
-			"app_name": info.AppName,
-			"icon_url": info.IconURL,
-		})
-	}
-
-	status := model.StatusFull
-	if info.Available {
-		status = model.StatusAvailable
-	}
+// CheckNow checks m right away, outside its schedule, and reports whether the
+// check succeeded
+func (s *Scheduler) CheckNow(m *model.Monitor) bool {
+	return s.performCheck(m)
+}
 
+// performCheck checks m and saves the result, it reports whether the check
+// succeeded. What follows from the result is up to the subscribers of
+// CheckCompleted and StatusChanged, which run in the same transaction.
+func (s *Scheduler) performCheck(m *model.Monitor) bool {
+	now := time.Now()
+	// Captured first, the updates below write back into m
 	prevStatus := m.Status
 
 	repository.GetDB().Model(m).Updates(map[string]interface{}{
-		"status":     status,
-		"last_error": "",
+		"status":     model.StatusChecking,
+		"last_check": now,
 	})
 
-	if info.Available && s.notifier != nil {
-		shouldNotify := false
+	info, checkErr := s.checker.Check(m.AppID)
+	e := events.CheckCompleted{
+		Monitor:  m,
+		Previous: prevStatus,
+		Started:  now,
+		Duration: time.Since(now),
+		Err:      checkErr,
+	}
 
-		switch m.NotifyMode {
-		case model.NotifyLoop:
-			shouldNotify = true
-		case model.NotifyOnce:
-			if !m.Notified {
-				shouldNotify = true
-			}
-		case model.NotifyOnlyAvailable:
-			if prevStatus != model.StatusAvailable {
-				shouldNotify = true
-			}
+	var updates map[string]interface{}
+	if checkErr != nil {
+		updates = map[string]interface{}{
+			"status":     model.StatusError,
+			"last_error": checkErr.Error(),
 		}
+	} else {
+		e.Available = info.Available
+		e.AppName = info.AppName
+		e.IconURL = info.IconURL
+		e.Message = info.Message
+
+		status := model.StatusFull
+		if info.Available {
+			status = model.StatusAvailable
+		}
+		updates = map[string]interface{}{
+			"status":     status,
+			"last_error": "",
+		}
+		if m.AppName == "" && info.AppName != "" {
+			updates["app_name"] = info.AppName
+			updates["icon_url"] = info.IconURL
+		}
+		if status != model.StatusAvailable {
+			// The next availability raises a new alert
+			updates["alerted_at"] = nil
+			updates["acked_at"] = nil
+			updates["escalated"] = false
+		}
+	}
 
-		if shouldNotify {
-			title := "🎉 TestFlight 有位了!"
-			message := fmt.Sprintf("**%s**\n\n%s\n\n[点击加入](%s)",
-				info.AppName, info.Message, m.TestFlightURL)
-
-			if err := s.notifier.Send(title, message); err != nil {
-				log.Printf("Failed to send notification: %v", err)
-			} else {
-				repository.GetDB().Model(m).Update("notified", true)
-				log.Printf("Notification sent for %s", info.AppName)
+	err := events.Transaction(repository.GetDB(), func(tx *events.Tx) error {
+		if err := tx.Model(m).Updates(updates).Error; err != nil {
+			return err
+		}
+		if m.Status != prevStatus {
+			changed := events.StatusChanged{Tx: tx, Monitor: m, From: prevStatus, To: m.Status}
+			if err := s.bus.StatusChanged.Publish(changed); err != nil {
+				return err
 			}
 		}
+		e.Tx = tx
+		return s.bus.CheckCompleted.Publish(e)
+	})
+	if err != nil {
+		slog.Error("Failed to save check result", logging.MonitorID(m.ID), logging.AppID(m.AppID), logging.Err(err))
 	}
 
-	log.Printf("Checked %s: %s (available: %v)", m.AppID, info.AppName, info.Available)
+	if checkErr != nil {
+		slog.Warn("Check failed", logging.MonitorID(m.ID), logging.AppID(m.AppID),
+			logging.Duration(e.Duration), logging.Err(checkErr))
+		return false
+	}
+	slog.Info("Checked", logging.MonitorID(m.ID), logging.AppID(m.AppID), "app_name", info.AppName,
+		"status", m.Status, logging.Duration(e.Duration))
+	return true
 }
 
 func (s *Scheduler) GetNextCheckTime() time.Time {
@@ -217,6 +376,33 @@ func (s *Scheduler) GetNextCheckTime() time.Time {
 	return s.nextCheckAt
 }
 
+// IsRunning reports whether the scheduler was started and not stopped
+func (s *Scheduler) IsRunning() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.running
+}
+
+// LastSuccessfulCheck returns the latest successful check of any active
+// monitor, ok is false when no monitor is active
+func (s *Scheduler) LastSuccessfulCheck() (last time.Time, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, job := range s.jobs {
+		if job.Running && job.LastSuccess.After(last) {
+			last, ok = job.LastSuccess, true
+		}
+	}
+	return last, ok
+}
+
+// NotifierCount returns how many users have notifications configured
+func (s *Scheduler) NotifierCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.notifiers)
+}
+
 func (s *Scheduler) GetActiveJobCount() int {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
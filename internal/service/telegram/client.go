@@ -0,0 +1,168 @@
+// Package telegram is a minimal client for the Telegram Bot API
+package telegram
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client calls Bot API methods for one bot token
+type Client struct {
+	token  string
+	client *http.Client
+}
+
+// NewClient creates a new Bot API client with optional proxy
+func NewClient(token string, proxyURL string) *Client {
+	client := &http.Client{
+		// Long enough for getUpdates long polling
+		Timeout: 60 * time.Second,
+	}
+
+	if proxyURL != "" {
+		proxy, err := url.Parse(proxyURL)
+		if err == nil {
+			client.Transport = &http.Transport{
+				Proxy: http.ProxyURL(proxy),
+			}
+		}
+	}
+
+	return &Client{token: token, client: client}
+}
+
+// APIError is an error response from the Bot API
+type APIError struct {
+	Code        int
+	Description string
+	RetryAfter  int // seconds to wait when rate limited (code 429)
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("telegram API returned %d: %s", e.Code, e.Description)
+}
+
+type apiResponse struct {
+	OK          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	ErrorCode   int             `json:"error_code"`
+	Description string          `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+// RetryAfter returns how long Telegram asked to wait before calling again
+// when err is a rate limit, 0 otherwise
+func RetryAfter(err error) time.Duration {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusTooManyRequests {
+		return 0
+	}
+	return time.Duration(apiErr.RetryAfter) * time.Second
+}
+
+// Call invokes a Bot API method with a JSON payload and decodes the result
+// into result when it is not nil. Rate limited calls fail right away, see
+// RetryAfter, callers reschedule them instead of blocking.
+func (c *Client) Call(method string, payload interface{}, result interface{}) error {
+	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/%s", c.token, method)
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		// Keep the token out of logs and stored delivery errors
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = strings.Replace(urlErr.URL, c.token, "<token>", 1)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	var body apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return &APIError{Code: resp.StatusCode, Description: "invalid response"}
+	}
+	if !body.OK {
+		return &APIError{
+			Code:        body.ErrorCode,
+			Description: body.Description,
+			RetryAfter:  body.Parameters.RetryAfter,
+		}
+	}
+
+	if result != nil {
+		return json.Unmarshal(body.Result, result)
+	}
+	return nil
+}
+
+// Update is an incoming update from getUpdates or a webhook
+type Update struct {
+	UpdateID      int            `json:"update_id"`
+	Message       *Message       `json:"message"`
+	CallbackQuery *CallbackQuery `json:"callback_query"`
+}
+
+// Message is a chat message
+type Message struct {
+	MessageID       int                   `json:"message_id"`
+	MessageThreadID int                   `json:"message_thread_id,omitempty"`
+	Chat            Chat                  `json:"chat"`
+	From            *User                 `json:"from"`
+	Text            string                `json:"text"`
+	Entities        json.RawMessage       `json:"entities,omitempty"`
+	Photo           json.RawMessage       `json:"photo,omitempty"`
+	Caption         string                `json:"caption,omitempty"`
+	CaptionEntities json.RawMessage       `json:"caption_entities,omitempty"`
+	ReplyMarkup     *InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+}
+
+// CallbackQuery is sent when an inline keyboard button is pressed
+type CallbackQuery struct {
+	ID      string   `json:"id"`
+	From    User     `json:"from"`
+	Message *Message `json:"message"`
+	Data    string   `json:"data"`
+}
+
+// InlineKeyboardMarkup is a keyboard attached to a message
+type InlineKeyboardMarkup struct {
+	InlineKeyboard [][]InlineKeyboardButton `json:"inline_keyboard"`
+}
+
+// InlineKeyboardButton opens a URL or sends callback data when pressed
+type InlineKeyboardButton struct {
+	Text         string `json:"text"`
+	URL          string `json:"url,omitempty"`
+	CallbackData string `json:"callback_data,omitempty"`
+}
+
+// Chat is a private chat, group or channel
+type Chat struct {
+	ID   int64  `json:"id"`
+	Type string `json:"type"`
+}
+
+// User is a Telegram user or bot
+type User struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
@@ -0,0 +1,197 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"tf-monitor/internal/logging"
+	"tf-monitor/internal/metrics"
+	"tf-monitor/internal/model"
+	"tf-monitor/internal/repository"
+)
+
+// Headers sent with every delivery
+const (
+	HeaderEvent     = "X-TFMonitor-Event"
+	HeaderDelivery  = "X-TFMonitor-Delivery"
+	HeaderSignature = "X-TFMonitor-Signature-256" // "sha256=" and the hex HMAC of the body
+)
+
+const (
+	dispatchInterval = 5 * time.Second
+	dispatchBatch    = 20
+	maxAttempts      = 8
+	baseBackoff      = 30 * time.Second
+	maxBackoff       = time.Hour
+	requestTimeout   = 10 * time.Second
+	retention        = 14 * 24 * time.Hour
+)
+
+var (
+	client = newClient()
+	wake   = make(chan struct{}, 1)
+	stop   = make(chan struct{})
+)
+
+// Start runs the dispatcher that sends queued deliveries
+func Start() {
+	go run()
+}
+
+// Stop ends the dispatcher, deliveries left are sent after the next start
+func Stop() {
+	close(stop)
+}
+
+// wakeDispatcher makes the dispatcher run now instead of at its next tick
+func wakeDispatcher() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+func run() {
+	ticker := time.NewTicker(dispatchInterval)
+	defer ticker.Stop()
+
+	var lastPrune time.Time
+	for {
+		dispatch()
+		if time.Since(lastPrune) > time.Hour {
+			prune()
+			lastPrune = time.Now()
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatch sends the deliveries that are due
+func dispatch() {
+	var due []model.WebhookDelivery
+	repository.GetDB().
+		Where("status = ? AND next_attempt_at <= ?", model.NotificationPending, time.Now()).
+		Order("next_attempt_at asc").
+		Limit(dispatchBatch).
+		Find(&due)
+
+	for i := range due {
+		deliver(&due[i])
+	}
+}
+
+func deliver(d *model.WebhookDelivery) {
+	var w model.Webhook
+	err := repository.GetDB().First(&w, d.WebhookID).Error
+	if err == nil {
+		err = send(&w, d)
+	}
+	d.Attempts++
+
+	updates := map[string]interface{}{
+		"attempts":        d.Attempts,
+		"response_status": d.ResponseStatus,
+	}
+	logArgs := []any{"delivery_id", d.ID, "webhook_id", d.WebhookID, "event", d.Event}
+	switch {
+	case err == nil:
+		metrics.WebhookDeliveries.Inc("sent")
+		updates["status"] = model.NotificationSent
+		updates["delivered_at"] = time.Now()
+		updates["last_error"] = ""
+		slog.Info("Webhook delivered", logArgs...)
+	case d.Attempts >= maxAttempts:
+		metrics.WebhookDeliveries.Inc("failed")
+		updates["status"] = model.NotificationFailed
+		updates["last_error"] = err.Error()
+		slog.Error("Webhook delivery failed, giving up", append(logArgs, "attempts", d.Attempts, logging.Err(err))...)
+	default:
+		metrics.WebhookDeliveries.Inc("retry")
+		delay := backoff(d.Attempts)
+		updates["last_error"] = err.Error()
+		updates["next_attempt_at"] = time.Now().Add(delay)
+		slog.Warn("Webhook delivery failed, retrying", append(logArgs, "retry_in", delay.String(), logging.Err(err))...)
+	}
+	repository.GetDB().Model(d).Updates(updates)
+}
+
+// send posts the delivery to the webhook and keeps the response status in
+// d. The body is discarded, it could hold anything the endpoint returns.
+func send(w *model.Webhook, d *model.WebhookDelivery) error {
+	d.ResponseStatus = 0
+
+	body := []byte(d.Payload)
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "TF-Monitor-Webhook")
+	req.Header.Set(HeaderEvent, d.Event)
+	req.Header.Set(HeaderDelivery, d.GUID)
+	req.Header.Set(HeaderSignature, Sign(string(w.Secret), body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	d.ResponseStatus = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("endpoint responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// Sign returns the signature header value of body for secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// backoff doubles the delay with every attempt
+func backoff(attempts int) time.Duration {
+	return min(baseBackoff<<(attempts-1), maxBackoff)
+}
+
+// Redeliver queues the event of d again under the same delivery ID, it is
+// sent right away with a fresh set of attempts
+func Redeliver(d *model.WebhookDelivery) (*model.WebhookDelivery, error) {
+	again := model.WebhookDelivery{
+		WebhookID:     d.WebhookID,
+		UserID:        d.UserID,
+		MonitorID:     d.MonitorID,
+		GUID:          d.GUID,
+		Event:         d.Event,
+		Payload:       d.Payload,
+		Redelivery:    true,
+		Status:        model.NotificationPending,
+		NextAttemptAt: time.Now(),
+	}
+	if err := repository.GetDB().Create(&again).Error; err != nil {
+		return nil, err
+	}
+	wakeDispatcher()
+	return &again, nil
+}
+
+// prune removes finished deliveries past the retention period
+func prune() {
+	repository.GetDB().Unscoped().
+		Where("status <> ? AND updated_at < ?", model.NotificationPending, time.Now().Add(-retention)).
+		Delete(&model.WebhookDelivery{})
+}
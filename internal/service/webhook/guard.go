@@ -0,0 +1,110 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+// errBlockedAddress is returned for endpoints on this host or a private
+// network, which users must not be able to reach through the server
+var errBlockedAddress = errors.New("webhook address is on a loopback, private or link-local network")
+
+// allowPrivate lets webhooks reach private networks, for deployments whose
+// receivers run next to the server
+var allowPrivate bool
+
+// AllowPrivateNetworks sets whether webhooks may reach loopback, private and
+// link-local addresses
+func AllowPrivateNetworks(allow bool) {
+	allowPrivate = allow
+}
+
+// Ranges not covered by the netip predicates
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),   // reserved, includes broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64 of IPv4 addresses
+}
+
+// blocked reports whether ip must not be reached by webhooks
+func blocked(ip netip.Addr) bool {
+	if allowPrivate {
+		return false
+	}
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return true
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkDial rejects connections to blocked addresses. It runs after the
+// host was resolved, so a name cannot point somewhere else once the URL was
+// saved.
+func checkDial(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("webhook address %q: %w", address, err)
+	}
+	if blocked(ip) {
+		return errBlockedAddress
+	}
+	return nil
+}
+
+// newClient returns the client deliveries are sent with. It connects
+// directly, a proxy would make the dial check see the proxy instead of the
+// endpoint, and does not follow redirects, which could lead anywhere.
+func newClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: requestTimeout,
+		Control: checkDial,
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Transport: transport,
+		Timeout:   requestTimeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// ValidateURL checks that rawURL is an absolute http or https URL that does
+// not name a blocked address. Host names are resolved when delivering.
+func ValidateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an http or https URL")
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if !allowPrivate && (host == "localhost" || strings.HasSuffix(host, ".localhost")) {
+		return errBlockedAddress
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && blocked(ip) {
+		return errBlockedAddress
+	}
+	return nil
+}
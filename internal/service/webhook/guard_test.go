@@ -0,0 +1,132 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// allowPrivateNetworks sets AllowPrivateNetworks for the test
+func allowPrivateNetworks(t *testing.T, allow bool) {
+	t.Helper()
+	AllowPrivateNetworks(allow)
+	t.Cleanup(func() { AllowPrivateNetworks(false) })
+}
+
+func TestBlocked(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.215.14", false},
+		{"2606:2800:21f:cb07:6820:80da:af6b:8b2c", false},
+		{"127.0.0.1", true},
+		{"127.1.2.3", true},
+		{"::1", true},
+		{"10.0.0.1", true},
+		{"172.16.0.1", true},
+		{"172.32.0.1", false},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true}, // cloud metadata
+		{"fe80::1", true},
+		{"fc00::1", true},
+		{"fd12:3456::1", true},
+		{"0.0.0.0", true},
+		{"::", true},
+		{"100.64.0.1", true},
+		{"224.0.0.1", true},
+		{"ff02::1", true},
+		{"255.255.255.255", true},
+		{"::ffff:127.0.0.1", true},
+		{"::ffff:10.0.0.1", true},
+		{"::ffff:93.184.215.14", false},
+		{"64:ff9b::7f00:1", true},
+	}
+	for _, tt := range tests {
+		if got := blocked(netip.MustParseAddr(tt.ip)); got != tt.want {
+			t.Errorf("blocked(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+
+	allowPrivateNetworks(t, true)
+	if blocked(netip.MustParseAddr("127.0.0.1")) {
+		t.Error("loopback blocked although private networks are allowed")
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr string
+	}{
+		{"https://hooks.example.com/tf", ""},
+		{"http://93.184.215.14:8080/hook", ""},
+		{"ftp://example.com/hook", "http or https"},
+		{"https://", "http or https"},
+		{"/relative", "http or https"},
+		{"http://127.0.0.1:8080/hook", "loopback"},
+		{"http://[::1]/hook", "loopback"},
+		{"http://localhost/hook", "loopback"},
+		{"http://LOCALHOST./hook", "loopback"},
+		{"http://app.localhost/hook", "loopback"},
+		{"http://192.168.1.10/hook", "private"},
+		{"http://169.254.169.254/latest/meta-data", "link-local"},
+		{"http://[::ffff:10.0.0.1]/hook", "private"},
+	}
+	for _, tt := range tests {
+		err := ValidateURL(tt.url)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("ValidateURL(%q) = %v", tt.url, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("ValidateURL(%q) = %v, want %q", tt.url, err, tt.wantErr)
+		}
+	}
+
+	allowPrivateNetworks(t, true)
+	for _, url := range []string{"http://127.0.0.1:8080/hook", "http://localhost/hook", "http://192.168.1.10/hook"} {
+		if err := ValidateURL(url); err != nil {
+			t.Errorf("ValidateURL(%q) with private networks allowed = %v", url, err)
+		}
+	}
+}
+
+func TestClientChecksResolvedAddress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// The check runs on the address dialed, whatever name led to it
+	_, err := newClient().Get(server.URL)
+	if !errors.Is(err, errBlockedAddress) {
+		t.Errorf("request to %s = %v, want errBlockedAddress", server.URL, err)
+	}
+}
+
+func TestClientIgnoresRedirects(t *testing.T) {
+	allowPrivateNetworks(t, true)
+	followed := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hook", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/internal", http.StatusFound)
+	})
+	mux.HandleFunc("/internal", func(w http.ResponseWriter, r *http.Request) {
+		followed = true
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resp, err := newClient().Post(server.URL+"/hook", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound || followed {
+		t.Errorf("status = %d, followed = %v, want the redirect itself", resp.StatusCode, followed)
+	}
+}
@@ -0,0 +1,168 @@
+// Package webhook delivers monitor events to the HTTP endpoints users
+// registered. Deliveries are queued with the change they report and sent in
+// the background with retries, signed with the secret of the webhook.
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"tf-monitor/internal/model"
+	"tf-monitor/internal/service/events"
+)
+
+// Event types a webhook can subscribe to
+const (
+	EventMonitorCreated = "monitor.created"
+	EventMonitorDeleted = "monitor.deleted"
+	EventStatusChanged  = "monitor.status_changed" // a check changed the status
+	EventMonitorExpired = "monitor.expired"
+	EventMonitorError   = "monitor.error" // a check failed after the monitor was fine
+)
+
+// EventTypes lists the event types in the order they are documented
+var EventTypes = []string{
+	EventMonitorCreated,
+	EventMonitorDeleted,
+	EventStatusChanged,
+	EventMonitorExpired,
+	EventMonitorError,
+}
+
+// Payload is the JSON body of a delivery
+type Payload struct {
+	Event          string              `json:"event"`
+	Delivery       string              `json:"delivery"` // same for redeliveries
+	OccurredAt     time.Time           `json:"occurredAt"`
+	Monitor        MonitorPayload      `json:"monitor"`
+	PreviousStatus model.MonitorStatus `json:"previousStatus,omitempty"`
+}
+
+// MonitorPayload is the state of the monitor an event is about
+type MonitorPayload struct {
+	ID            uint                `json:"id"`
+	AppID         string              `json:"appId"`
+	AppName       string              `json:"appName"`
+	TestFlightURL string              `json:"testFlightUrl"`
+	Status        model.MonitorStatus `json:"status"`
+	Enabled       bool                `json:"enabled"`
+	LastCheck     *time.Time          `json:"lastCheck"`
+	LastError     string              `json:"lastError"`
+	ExpireAt      *time.Time          `json:"expireAt"`
+}
+
+// ValidateEvents checks that events is a non-empty list of known event types
+func ValidateEvents(events []string) error {
+	if len(events) == 0 {
+		return fmt.Errorf("at least one event is required")
+	}
+	for _, e := range events {
+		if !slices.Contains(EventTypes, e) {
+			return fmt.Errorf("unknown event %q", e)
+		}
+	}
+	return nil
+}
+
+// GenerateSecret returns a random secret for a webhook registered without one
+func GenerateSecret() (string, error) {
+	return randomHex(20)
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Listen queues deliveries for the events of bus
+func Listen(bus *events.Bus) {
+	bus.MonitorCreated.Subscribe(func(e events.MonitorCreated) error {
+		return enqueue(e.Tx, EventMonitorCreated, e.Monitor, "")
+	})
+	bus.MonitorDeleted.Subscribe(func(e events.MonitorDeleted) error {
+		return enqueue(e.Tx, EventMonitorDeleted, e.Monitor, "")
+	})
+	bus.StatusChanged.Subscribe(func(e events.StatusChanged) error {
+		if err := enqueue(e.Tx, EventStatusChanged, e.Monitor, e.From); err != nil {
+			return err
+		}
+		if e.To == model.StatusError {
+			return enqueue(e.Tx, EventMonitorError, e.Monitor, e.From)
+		}
+		return nil
+	})
+	bus.MonitorExpired.Subscribe(func(e events.MonitorExpired) error {
+		return enqueue(e.Tx, EventMonitorExpired, e.Monitor, e.Previous)
+	})
+}
+
+// enqueue queues a delivery of the event to each active webhook of the owner
+// of m that subscribed to it
+func enqueue(tx *events.Tx, event string, m *model.Monitor, prev model.MonitorStatus) error {
+	// The secret is left out, it is only needed to send and a value that
+	// cannot be decrypted must not roll back the change
+	var webhooks []model.Webhook
+	if err := tx.Select("id", "user_id", "events").
+		Where("user_id = ? AND active = ?", m.UserID, true).
+		Find(&webhooks).Error; err != nil {
+		return err
+	}
+
+	queued := false
+	for _, w := range webhooks {
+		if !w.Subscribes(event) {
+			continue
+		}
+		guid, err := randomHex(16)
+		if err != nil {
+			return err
+		}
+		body, err := json.Marshal(Payload{
+			Event:      event,
+			Delivery:   guid,
+			OccurredAt: time.Now(),
+			Monitor: MonitorPayload{
+				ID:            m.ID,
+				AppID:         m.AppID,
+				AppName:       m.AppName,
+				TestFlightURL: m.TestFlightURL,
+				Status:        m.Status,
+				Enabled:       m.Enabled,
+				LastCheck:     m.LastCheck,
+				LastError:     m.LastError,
+				ExpireAt:      m.ExpireAt,
+			},
+			PreviousStatus: prev,
+		})
+		if err != nil {
+			return err
+		}
+
+		d := model.WebhookDelivery{
+			WebhookID:     w.ID,
+			UserID:        w.UserID,
+			MonitorID:     m.ID,
+			GUID:          guid,
+			Event:         event,
+			Payload:       string(body),
+			Status:        model.NotificationPending,
+			NextAttemptAt: time.Now(),
+		}
+		if err := tx.Create(&d).Error; err != nil {
+			return err
+		}
+		queued = true
+	}
+
+	if queued {
+		tx.AfterCommit(wakeDispatcher)
+	}
+	return nil
+}
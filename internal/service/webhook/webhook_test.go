@@ -0,0 +1,61 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"event":"monitor.created"}`)
+	tests := []struct {
+		name   string
+		secret string
+		body   []byte
+		want   string
+	}{
+		// Same as: printf '%s' "$body" | openssl dgst -sha256 -hmac "$secret"
+		{"known value", "key", []byte("The quick brown fox jumps over the lazy dog"),
+			"sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"},
+		{"empty body", "key", nil,
+			"sha256=5d5d139563c95b5967b9bd9a8c9b233a9dedb45072794cd232dc1b74832607d0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sign(tt.secret, tt.body); got != tt.want {
+				t.Errorf("Sign = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// What a receiver does to verify a delivery
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	if got, want := Sign("secret", body), "sha256="+hex.EncodeToString(mac.Sum(nil)); !hmac.Equal([]byte(got), []byte(want)) {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+	if Sign("secret", body) == Sign("other", body) {
+		t.Error("signature does not depend on the secret")
+	}
+	if Sign("secret", body) == Sign("secret", append(body, ' ')) {
+		t.Error("signature does not depend on the body")
+	}
+}
+
+func TestValidateEvents(t *testing.T) {
+	tests := []struct {
+		events  []string
+		wantErr bool
+	}{
+		{EventTypes, false},
+		{[]string{EventStatusChanged}, false},
+		{nil, true},
+		{[]string{EventMonitorCreated, "monitor.unknown"}, true},
+	}
+	for _, tt := range tests {
+		if err := ValidateEvents(tt.events); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateEvents(%v) = %v", tt.events, err)
+		}
+	}
+}
@@ -1,10 +1,45 @@
 import axios from 'axios'
-import type { Monitor, CreateMonitorParams, TelegramConfig, StatusResponse } from '../types'
+import type { Monitor, MonitorUpdate, CreateMonitorParams, TelegramConfig, TelegramTestParams, StatusResponse, User } from '../types'
 
 const api = axios.create({
-  baseURL: '/api'
+  baseURL: 'api'
 })
 
+let onUnauthorized: (() => void) | null = null
+
+export const setUnauthorizedHandler = (handler: () => void) => {
+  onUnauthorized = handler
+}
+
+api.interceptors.response.use(
+  (response) => response,
+  (error) => {
+    if (error.response?.status === 401 && onUnauthorized) {
+      onUnauthorized()
+    }
+    return Promise.reject(error)
+  }
+)
+
+export const getAuthProviders = async (): Promise<{ local: boolean; oidc: boolean }> => {
+  const response = await api.get('/auth/providers')
+  return response.data
+}
+
+export const login = async (username: string, password: string): Promise<User> => {
+  const response = await api.post('/auth/login', { username, password })
+  return response.data.data
+}
+
+export const logout = async (): Promise<void> => {
+  await api.post('/auth/logout')
+}
+
+export const getMe = async (): Promise<User> => {
+  const response = await api.get('/auth/me')
+  return response.data.data
+}
+
 export const getMonitors = async (): Promise<Monitor[]> => {
   const response = await api.get('/monitors')
   return response.data.data || []
@@ -20,11 +55,23 @@ export const toggleMonitor = async (id: number): Promise<Monitor> => {
   return response.data
 }
 
+export const ackMonitor = async (id: number): Promise<Monitor> => {
+  const response = await api.post(`/monitors/${id}/ack`)
+  return response.data.data
+}
+
+// openEvents connects to the live event stream, lastEventId resumes after an
+// event received on an earlier connection
+export const openEvents = (lastEventId = ''): EventSource => {
+  const query = lastEventId ? `?lastEventId=${encodeURIComponent(lastEventId)}` : ''
+  return new EventSource(`api/events${query}`)
+}
+
 export const deleteMonitor = async (id: number): Promise<void> => {
   await api.delete(`/monitors/${id}`)
 }
 
-export const updateMonitor = async (id: number, data: { interval?: number; duration?: number }): Promise<Monitor> => {
+export const updateMonitor = async (id: number, data: MonitorUpdate): Promise<Monitor> => {
   const response = await api.put(`/monitors/${id}`, data)
   return response.data.data
 }
@@ -34,12 +81,11 @@ export const getTelegramConfig = async (): Promise<TelegramConfig> => {
   return response.data
 }
 
-export const updateTelegramConfig = async (config: TelegramConfig): Promise<TelegramConfig> => {
-  const response = await api.put('/telegram', config)
-  return response.data
+export const updateTelegramConfig = async (config: TelegramConfig): Promise<void> => {
+  await api.put('/telegram', config)
 }
 
-export const testTelegram = async (config: { botToken: string; chatId: string }): Promise<void> => {
+export const testTelegram = async (config: TelegramTestParams): Promise<void> => {
   await api.post('/telegram/test', config)
 }
 
@@ -24,6 +24,13 @@ const messages = {
       telegram: 'Telegram 通知',
       botToken: 'Bot Token',
       chatId: 'Chat ID',
+      threadId: '话题 ID',
+      silent: '静默发送',
+      addChat: '添加聊天',
+      sendIcon: '附带应用图标',
+      digest: '接收摘要',
+      escalation: '接收升级提醒',
+      group: '分组',
       enableNotify: '启用通知',
       save: '保存',
       testSend: '测试发送',
@@ -34,6 +41,7 @@ const messages = {
       checking: '检测中',
       error: '错误',
       expired: '已过期',
+      joined: '已加入',
       loading: '加载中...',
       pause: '暂停',
       resume: '恢复',
@@ -45,7 +53,22 @@ const messages = {
       forever: '永久',
       hours: '小时',
       seconds: '秒',
+      minutes: '分钟',
       cancel: '取消',
+      minRepeatInterval: '最短提醒间隔',
+      maxPerHour: '每小时最多提醒',
+      quietHours: '免打扰时段',
+      quietMode: '免打扰期间',
+      quietQueue: '延后发送',
+      quietDrop: '丢弃',
+      inherit: '使用全局设置',
+      timezone: '时区',
+      policyHint: '留空或填 0 使用全局设置，免打扰时段填 off 可关闭全局时段',
+      escalateAfter: '未确认升级',
+      escalateHint: '提醒发出后在此时间内未确认，则重新发送并通知勾选 🚨 的聊天，0 表示关闭',
+      ack: '确认',
+      routes: '通知分组',
+      routesHint: '多个分组用逗号分隔，只通知属于这些分组的聊天；留空使用 default 分组（未填写分组的聊天）',
     },
     empty: {
       title: '暂无监控',
@@ -63,6 +86,20 @@ const messages = {
       saved: '保存成功',
       testSuccess: '测试消息已发送，请检查 Telegram',
       testFailed: '发送失败',
+      digestSchedule: '摘要发送时间',
+      digestTimezone: '摘要时区',
+      digestHint: '填写每日时间（如 09:00）或间隔（如 15m），留空关闭。勾选 📋 的聊天只接收摘要，不接收单条提醒',
+      botUsers: '机器人用户',
+      botUsersHint: '在群组中可以使用机器人命令和提醒按钮的 Telegram 用户 ID，用逗号分隔；私聊不受限制',
+    },
+    auth: {
+      title: '登录',
+      username: '用户名',
+      password: '密码',
+      login: '登录',
+      logout: '退出登录',
+      failed: '用户名或密码错误',
+      sso: '使用 SSO 登录',
     },
   },
   'en-US': {
@@ -88,6 +125,13 @@ const messages = {
       telegram: 'Telegram Notification',
       botToken: 'Bot Token',
       chatId: 'Chat ID',
+      threadId: 'Topic ID',
+      silent: 'Silent delivery',
+      addChat: 'Add chat',
+      sendIcon: 'Attach app icon',
+      digest: 'Receive digests',
+      escalation: 'Receive escalations',
+      group: 'Group',
       enableNotify: 'Enable Notifications',
       save: 'Save',
       testSend: 'Test Send',
@@ -98,6 +142,7 @@ const messages = {
       checking: 'Checking',
       error: 'Error',
       expired: 'Expired',
+      joined: 'Joined',
       loading: 'Loading...',
       pause: 'Pause',
       resume: 'Resume',
@@ -109,7 +154,22 @@ const messages = {
       forever: 'Forever',
       hours: 'hours',
       seconds: 'seconds',
+      minutes: 'minutes',
       cancel: 'Cancel',
+      minRepeatInterval: 'Min. repeat interval',
+      maxPerHour: 'Max. alerts per hour',
+      quietHours: 'Quiet hours',
+      quietMode: 'During quiet hours',
+      quietQueue: 'Deliver later',
+      quietDrop: 'Drop',
+      inherit: 'Use global setting',
+      timezone: 'Time zone',
+      policyHint: 'Empty or 0 uses the global setting, set quiet hours to off to disable the global ones',
+      escalateAfter: 'Escalate if unacknowledged',
+      escalateHint: 'An alert not acknowledged within this time is resent, also to the chats marked 🚨. 0 disables escalation',
+      ack: 'Acknowledge',
+      routes: 'Notification groups',
+      routesHint: 'Comma separated, only chats in these groups are notified; empty uses the default group (chats without a group)',
     },
     empty: {
       title: 'No monitors',
@@ -127,6 +187,20 @@ const messages = {
       saved: 'Saved successfully',
       testSuccess: 'Test message sent! Check your Telegram.',
       testFailed: 'Failed to send',
+      digestSchedule: 'Digest schedule',
+      digestTimezone: 'Digest time zone',
+      digestHint: 'A daily time such as 09:00 or an interval such as 15m, empty to disable. Chats marked 📋 receive digests instead of individual alerts',
+      botUsers: 'Bot users',
+      botUsersHint: 'Comma separated Telegram user IDs that may use bot commands and alert buttons in group chats, private chats need no list',
+    },
+    auth: {
+      title: 'Sign in',
+      username: 'Username',
+      password: 'Password',
+      login: 'Sign in',
+      logout: 'Sign out',
+      failed: 'Invalid username or password',
+      sso: 'Sign in with SSO',
     },
   },
 }
@@ -1,10 +1,18 @@
+export interface User {
+  id: number
+  username: string
+  role: 'admin' | 'editor' | 'viewer'
+  createdAt: string
+}
+
 export interface Monitor {
   id: number
+  userId: number
   appId: string
   appName: string
   iconUrl: string
   testFlightUrl: string
-  status: 'available' | 'full' | 'checking' | 'error' | 'expired'
+  status: 'available' | 'full' | 'checking' | 'error' | 'expired' | 'joined'
   interval: number
   duration: number
   notifyMode: 'loop' | 'once' | 'only_available'
@@ -12,13 +20,59 @@ export interface Monitor {
   lastCheck: string | null
   lastError: string
   expireAt: string | null
+  snoozedUntil: string | null
+  policy: NotifyPolicy
+  escalateAfter: number
+  alertedAt: string | null
+  ackedAt: string | null
+  escalated: boolean
+  routes: string[]
   createdAt: string
 }
 
+// Payload of the monitor.checked and monitor.status events
+export type MonitorEvent = Pick<Monitor,
+  'id' | 'status' | 'enabled' | 'appName' | 'iconUrl' | 'lastCheck' | 'lastError' | 'alertedAt' | 'ackedAt' | 'escalated'
+> & { previousStatus: Monitor['status'] }
+
+export interface NotifyPolicy {
+  minRepeatInterval: number
+  maxPerHour: number
+  quietHours: string
+  quietMode: '' | 'queue' | 'drop'
+  timezone: string
+}
+
+export interface MonitorUpdate {
+  interval?: number
+  duration?: number
+  policy?: NotifyPolicy
+  escalateAfter?: number
+  routes?: string[]
+}
+
+export interface TelegramTarget {
+  chatId: string
+  threadId?: number
+  group?: string
+  silent: boolean
+  digest: boolean
+  escalation: boolean
+}
+
 export interface TelegramConfig {
   botToken: string
-  chatId: string
+  targets: TelegramTarget[]
+  botUsers: number[]
+  sendIcon: boolean
   enabled: boolean
+  digestSchedule: string
+  digestTimezone: string
+}
+
+export interface TelegramTestParams {
+  botToken: string
+  targets: TelegramTarget[]
 }
 
 export interface CreateMonitorParams {
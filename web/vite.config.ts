@@ -2,6 +2,8 @@ import { defineConfig } from 'vite'
 import vue from '@vitejs/plugin-vue'
 
 export default defineConfig({
+  // Relative URLs, so the build works under any BASE_PATH
+  base: './',
   plugins: [vue()],
   server: {
     proxy: {
@@ -0,0 +1,102 @@
+// Package web serves the frontend. The build in dist is embedded into the
+// binary, a directory on disk can be served instead during development.
+package web
+
+import (
+	"bytes"
+	"embed"
+	"html"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// dist/.gitkeep comes from public and keeps the pattern matching before the
+// frontend is built
+//
+//go:embed all:dist
+var dist embed.FS
+
+// Register serves the frontend under basePath from dir, or the embedded build
+// when dir is empty, for requests no other route handles. Unknown paths
+// outside the API get index.html so the app can route them.
+func Register(r *gin.Engine, dir, basePath string) error {
+	var fsys fs.FS
+	if dir != "" {
+		fsys = os.DirFS(dir)
+	} else {
+		sub, err := fs.Sub(dist, "dist")
+		if err != nil {
+			return err
+		}
+		fsys = sub
+	}
+	if _, err := fs.Stat(fsys, "index.html"); err != nil {
+		slog.Warn("Frontend not found, only the API is served", "dir", dir)
+	}
+
+	files := http.StripPrefix(basePath, http.FileServer(http.FS(fsys)))
+	r.NoRoute(func(c *gin.Context) {
+		rest, ok := strings.CutPrefix(c.Request.URL.Path, basePath)
+		if rest != "" && !strings.HasPrefix(rest, "/") {
+			ok = false
+		}
+		name := strings.TrimPrefix(path.Clean("/"+rest), "/")
+		if !ok || (c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead) ||
+			name == "api" || strings.HasPrefix(name, "api/") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+
+		if name != "" && name != "index.html" {
+			if info, err := fs.Stat(fsys, name); err == nil && !info.IsDir() {
+				c.Header("Cache-Control", cacheControl(name))
+				files.ServeHTTP(c.Writer, c.Request)
+				return
+			}
+			if strings.HasPrefix(name, "assets/") {
+				c.Status(http.StatusNotFound)
+				return
+			}
+		}
+
+		index, err := fs.ReadFile(fsys, "index.html")
+		if err != nil {
+			c.String(http.StatusNotFound, "frontend not built")
+			return
+		}
+		c.Header("Cache-Control", "no-cache")
+		c.Data(http.StatusOK, "text/html; charset=utf-8", withBase(index, basePath))
+	})
+	return nil
+}
+
+// withBase adds a base element to the head of index. The build refers to its
+// files and the API by relative URLs, which resolve against it on every route
+// of the app.
+func withBase(index []byte, basePath string) []byte {
+	tag := `<base href="` + html.EscapeString(basePath) + `/">`
+	head := bytes.Index(index, []byte("<head>"))
+	if head < 0 {
+		return index
+	}
+	head += len("<head>")
+	out := make([]byte, 0, len(index)+len(tag))
+	out = append(out, index[:head]...)
+	out = append(out, tag...)
+	return append(out, index[head:]...)
+}
+
+// cacheControl returns the caching policy of a file. Vite puts a content hash
+// into the names of the files under assets, so they never change.
+func cacheControl(name string) string {
+	if strings.HasPrefix(name, "assets/") {
+		return "public, max-age=31536000, immutable"
+	}
+	return "no-cache"
+}